@@ -0,0 +1,425 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TwoPhaseApplier is an applier which switches to a new configuration in two
+// phases, e.g. to open new listeners or connection pools before anything
+// switches over.
+//
+// The appliers of a configuration are all prepared before the new
+// configuration replaces the current one and is broadcasted. If they are all
+// prepared successfully, they are committed. Otherwise the ones which have
+// been prepared are aborted, in reverse order, and the configuration is not
+// replaced. Plain appliers do their work while being prepared.
+type TwoPhaseApplier interface {
+	// Prepare gets everything ready to switch to newConfig without
+	// disturbing the current configuration.
+	Prepare(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error
+	// Commit switches to newConfig once it has replaced the current
+	// configuration. An error can not prevent the configuration from being
+	// replaced anymore, it is only reported, see Status.CommitErrors.
+	Commit(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error
+	// Abort releases what has been prepared for newConfig.
+	Abort(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo)
+}
+
+// AddTwoPhaseApplier registers a two-phase applier. Prefixes work the same way
+// as with AddInfoApplier.
+func (m *Manager) AddTwoPhaseApplier(name interface{}, applier TwoPhaseApplier, prefixes ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Appliers without name can not be part of a cycle
+	_ = m.registerApplier(name, registeredApplier{
+		prepare:  applier.Prepare,
+		commit:   applier.Commit,
+		abort:    applier.Abort,
+		prefixes: prefixes,
+	})
+}
+
+// ApplierOption customizes the way a named applier is run.
+type ApplierOption func(a *registeredApplier)
+
+// After makes the applier run after the named appliers.
+func After(names ...string) ApplierOption {
+	return func(a *registeredApplier) {
+		a.after = append(a.after, names...)
+	}
+}
+
+// Before makes the applier run before the named appliers.
+func Before(names ...string) ApplierOption {
+	return func(a *registeredApplier) {
+		a.before = append(a.before, names...)
+	}
+}
+
+// Priority sets the priority of the applier. Among the appliers whose
+// dependencies have been run, the ones with the highest priority are run
+// first. It defaults to 0.
+func Priority(priority int) ApplierOption {
+	return func(a *registeredApplier) {
+		a.priority = priority
+	}
+}
+
+// OnChangesTo makes the applier skipped when none of the changes affect one of
+// the given path prefixes, see AddInfoApplier.
+func OnChangesTo(prefixes ...string) ApplierOption {
+	return func(a *registeredApplier) {
+		a.prefixes = append(a.prefixes, prefixes...)
+	}
+}
+
+// AddNamedApplier registers an applier which other appliers can depend on.
+// Appliers are run in an order which satisfies the After and Before
+// constraints, dependencies on appliers which are not registered are ignored.
+// Appliers which have no dependency on each other are run in parallel when the
+// configuration has been created with WithParallelAppliers.
+//
+// An error is returned if the name is already used or if the constraints form
+// a cycle, in which case the applier is not registered.
+func (m *Manager) AddNamedApplier(name interface{}, applierName string, applier ApplierCtx, opts ...ApplierOption) error {
+	a := registeredApplier{name: applierName, prepare: applier}
+
+	for _, opt := range opts {
+		opt(&a)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.registerApplier(name, a)
+}
+
+// AddNamedTwoPhaseApplier is like AddNamedApplier for two-phase appliers.
+func (m *Manager) AddNamedTwoPhaseApplier(name interface{}, applierName string, applier TwoPhaseApplier, opts ...ApplierOption) error {
+	a := registeredApplier{
+		name:    applierName,
+		prepare: applier.Prepare,
+		commit:  applier.Commit,
+		abort:   applier.Abort,
+	}
+
+	for _, opt := range opts {
+		opt(&a)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.registerApplier(name, a)
+}
+
+// registeredApplier is an applier and the path prefixes it is interested in.
+// Plain appliers only have a prepare function.
+type registeredApplier struct {
+	prepare  ApplierCtx
+	commit   ApplierCtx
+	abort    func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo)
+	prefixes []string
+
+	// name is empty for appliers registered without name
+	name     string
+	after    []string
+	before   []string
+	priority int
+	// seq is the registration order of the applier
+	seq int
+	// level is the length of the longest chain of dependencies of the applier
+	level int
+}
+
+// registerApplier adds the applier to the ones of the named configuration,
+// which are kept sorted in the order they have to be run. It must be called
+// with the lock held.
+func (m *Manager) registerApplier(name interface{}, applier registeredApplier) error {
+	if m.appliers == nil {
+		m.appliers = make(map[interface{}][]registeredApplier)
+	}
+
+	m.applierSeq++
+	applier.seq = m.applierSeq
+
+	appliers := make([]registeredApplier, 0, len(m.appliers[name])+1)
+	appliers = append(appliers, m.appliers[name]...)
+	appliers = append(appliers, applier)
+
+	sorted, err := sortAppliers(appliers)
+
+	if err != nil {
+		return err
+	}
+
+	m.appliers[name] = sorted
+
+	return nil
+}
+
+// sortAppliers sorts the appliers in topological order of their dependencies,
+// running first the ones with the highest priority and then the ones which
+// have been registered first. It also computes the level of each applier.
+func sortAppliers(appliers []registeredApplier) ([]registeredApplier, error) {
+	index := make(map[string]int)
+
+	for i, a := range appliers {
+		if len(a.name) == 0 {
+			continue
+		}
+
+		if _, ok := index[a.name]; ok {
+			return nil, fmt.Errorf("applier `%s` already registered", a.name)
+		}
+
+		index[a.name] = i
+	}
+
+	// next[i] lists the appliers which have to run after appliers[i]
+	next := make([][]int, len(appliers))
+	pending := make([]int, len(appliers))
+
+	for i, a := range appliers {
+		for _, dep := range a.after {
+			if j, ok := index[dep]; ok {
+				next[j] = append(next[j], i)
+				pending[i]++
+			}
+		}
+
+		for _, dep := range a.before {
+			if j, ok := index[dep]; ok {
+				next[i] = append(next[i], j)
+				pending[j]++
+			}
+		}
+	}
+
+	var ready []int
+	for i := range appliers {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	levels := make([]int, len(appliers))
+	sorted := make([]registeredApplier, 0, len(appliers))
+
+	for len(ready) > 0 {
+		sort.Slice(ready, func(x, y int) bool {
+			a, b := appliers[ready[x]], appliers[ready[y]]
+
+			if a.priority != b.priority {
+				return a.priority > b.priority
+			}
+
+			return a.seq < b.seq
+		})
+
+		i := ready[0]
+		ready = ready[1:]
+
+		a := appliers[i]
+		a.level = levels[i]
+		sorted = append(sorted, a)
+
+		for _, j := range next[i] {
+			if levels[i]+1 > levels[j] {
+				levels[j] = levels[i] + 1
+			}
+
+			pending[j]--
+
+			if pending[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+
+	if len(sorted) < len(appliers) {
+		var names []string
+
+		for i, a := range appliers {
+			if pending[i] > 0 {
+				names = append(names, a.name)
+			}
+		}
+
+		return nil, fmt.Errorf("applier dependency cycle between: %s", strings.Join(names, ", "))
+	}
+
+	return sorted, nil
+}
+
+// concerned returns true if the applier has to be run for the given changes.
+func (a registeredApplier) concerned(changes Changes) bool {
+	if len(a.prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range a.prefixes {
+		if changes.Has(prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// callApplier runs f, converting its panic, if any, into an error.
+func callApplier(f ApplierCtx, ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) (err error) {
+	defer recoverPanic(&err)
+
+	return f(ctx, currentConfig, newConfig, info)
+}
+
+// prepareAppliers prepares the appliers registered for the named
+// configuration, skipping the ones which are not concerned by the changes. If
+// parallel is true, the appliers of the same level are prepared concurrently.
+// If one of them fails, the ones which have been prepared are aborted with
+// abortCtx and the error is returned.
+func (m *Manager) prepareAppliers(ctx context.Context, abortCtx context.Context, name interface{}, parallel bool, currentConfig Config, newConfig Config, info *ReloadInfo) ([]registeredApplier, error) {
+	m.mu.RLock()
+	appliers := m.appliers[name]
+	m.mu.RUnlock()
+
+	var concerned []registeredApplier
+
+	for _, applier := range appliers {
+		if !applier.concerned(info.Changes) {
+			m.logger.Tracef("Skipping applier not concerned by changes %v", applier.prefixes)
+			continue
+		}
+
+		concerned = append(concerned, applier)
+	}
+
+	// Appliers are prepared one at a time unless they are run in parallel
+	var groups [][]registeredApplier
+
+	if parallel {
+		groups = groupByLevel(concerned)
+	} else {
+		for _, applier := range concerned {
+			groups = append(groups, []registeredApplier{applier})
+		}
+	}
+
+	var prepared []registeredApplier
+
+	for _, group := range groups {
+		errs := make([]error, len(group))
+
+		if err := ctx.Err(); err != nil {
+			m.abortAppliers(abortCtx, prepared, currentConfig, newConfig, info)
+			return nil, err
+		}
+
+		if len(group) == 1 {
+			errs[0] = callApplier(group[0].prepare, ctx, currentConfig, newConfig, info)
+		} else {
+			var wg sync.WaitGroup
+
+			for i := range group {
+				wg.Add(1)
+
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = callApplier(group[i].prepare, ctx, currentConfig, newConfig, info)
+				}(i)
+			}
+
+			wg.Wait()
+		}
+
+		var firstErr error
+
+		for i, err := range errs {
+			if err == nil {
+				prepared = append(prepared, group[i])
+			} else if firstErr == nil {
+				firstErr = err
+			} else {
+				m.logger.Errorf("Error while applying new conf: %v", err)
+			}
+		}
+
+		if firstErr != nil {
+			m.abortAppliers(abortCtx, prepared, currentConfig, newConfig, info)
+			return nil, firstErr
+		}
+	}
+
+	return prepared, nil
+}
+
+// groupByLevel groups sorted appliers by level, keeping their order.
+func groupByLevel(appliers []registeredApplier) [][]registeredApplier {
+	var groups [][]registeredApplier
+
+	for _, applier := range appliers {
+		for len(groups) <= applier.level {
+			groups = append(groups, nil)
+		}
+
+		groups[applier.level] = append(groups[applier.level], applier)
+	}
+
+	// Levels of skipped appliers may be empty
+	nonEmpty := groups[:0]
+
+	for _, group := range groups {
+		if len(group) > 0 {
+			nonEmpty = append(nonEmpty, group)
+		}
+	}
+
+	return nonEmpty
+}
+
+// abortAppliers aborts the given appliers in reverse order.
+func (m *Manager) abortAppliers(ctx context.Context, appliers []registeredApplier, currentConfig Config, newConfig Config, info *ReloadInfo) {
+	for i := len(appliers) - 1; i >= 0; i-- {
+		abort := appliers[i].abort
+
+		if abort == nil {
+			continue
+		}
+
+		err := callApplier(func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+			abort(ctx, currentConfig, newConfig, info)
+			return nil
+		}, ctx, currentConfig, newConfig, info)
+
+		if err != nil {
+			m.logger.Errorf("Error while aborting new conf: %v", err)
+		}
+	}
+}
+
+// commitAppliers commits the given appliers. All of them are committed even if
+// some fail, their errors are returned.
+func (m *Manager) commitAppliers(ctx context.Context, appliers []registeredApplier, currentConfig Config, newConfig Config, info *ReloadInfo) []error {
+	var errs []error
+
+	for _, applier := range appliers {
+		if applier.commit == nil {
+			continue
+		}
+
+		err := callApplier(applier.commit, ctx, currentConfig, newConfig, info)
+
+		if err != nil {
+			m.logger.Errorf("Error while committing new conf: %v", err)
+			errs = append(errs, fmt.Errorf("error while committing new conf: %w", err))
+		}
+	}
+
+	return errs
+}
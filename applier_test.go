@@ -0,0 +1,263 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingApplier is a TwoPhaseApplier recording the calls it receives.
+type recordingApplier struct {
+	name  string
+	calls *[]string
+	fail  bool
+	m     *Manager
+}
+
+func (a *recordingApplier) Prepare(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+	*a.calls = append(*a.calls, a.name+".prepare")
+
+	if a.fail && info.Reason != ReasonInitial {
+		return errors.New("prepare failed")
+	}
+
+	return nil
+}
+
+func (a *recordingApplier) Commit(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+	*a.calls = append(*a.calls, a.name+".commit")
+
+	// The new configuration has been swapped before commit
+	if a.m.GetConfig(info.Name) != newConfig {
+		return errors.New("configuration not swapped before commit")
+	}
+
+	return nil
+}
+
+func (a *recordingApplier) Abort(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) {
+	*a.calls = append(*a.calls, a.name+".abort")
+}
+
+func TestTwoPhaseAppliers(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "twophase"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	var calls []string
+	third := &recordingApplier{name: "c", calls: &calls, m: confManager}
+
+	confManager.AddTwoPhaseApplier(name, &recordingApplier{name: "a", calls: &calls, m: confManager})
+	confManager.AddAppliers(name, func(currentConfig Config, newConfig Config) error {
+		calls = append(calls, "plain")
+		return nil
+	})
+	confManager.AddTwoPhaseApplier(name, &recordingApplier{name: "b", calls: &calls, m: confManager})
+	confManager.AddTwoPhaseApplier(name, third)
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"a.prepare", "plain", "b.prepare", "c.prepare", "a.commit", "b.commit", "c.commit"}
+	assertCalls(t, calls, expected)
+
+	// A failed preparation aborts the prepared appliers in reverse order
+	calls = nil
+	third.fail = true
+	setArgs(t, "-vv")
+
+	if err := confManager.Reload(ctx, name); err == nil {
+		t.Fatal("expected the reload to fail")
+	}
+
+	expected = []string{"a.prepare", "plain", "b.prepare", "c.prepare", "b.abort", "a.abort"}
+	assertCalls(t, calls, expected)
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 1 {
+		t.Errorf("configuration should not have been replaced, verbose is %d", v)
+	}
+}
+
+// failingCommitApplier is a TwoPhaseApplier which fails to commit.
+type failingCommitApplier struct{}
+
+func (a failingCommitApplier) Prepare(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+	return nil
+}
+
+func (a failingCommitApplier) Commit(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+	if info.Reason == ReasonInitial {
+		return nil
+	}
+
+	return errors.New("commit failed")
+}
+
+func (a failingCommitApplier) Abort(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) {
+}
+
+func TestCommitErrors(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "commit"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	confManager.AddTwoPhaseApplier(name, failingCommitApplier{})
+	confManager.AddTwoPhaseApplier(name, failingCommitApplier{})
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Commit errors are reported without making the applied reload fail
+	setArgs(t, "-vv")
+
+	if err := confManager.Reload(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 2 {
+		t.Errorf("expected verbose to be 2, got %d", v)
+	}
+
+	status, _ := confManager.Status(name)
+
+	if status.LastError != nil || status.ConsecutiveFailures != 0 || status.LastSuccess != status.LastReload {
+		t.Errorf("expected the reload to be recorded as a success, got %+v", status)
+	}
+
+	if len(status.CommitErrors) != 2 {
+		t.Errorf("expected 2 commit errors, got %v", status.CommitErrors)
+	}
+}
+
+func assertCalls(t *testing.T, calls []string, expected []string) {
+	t.Helper()
+
+	if len(calls) != len(expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+
+	for i := range calls {
+		if calls[i] != expected[i] {
+			t.Fatalf("expected calls %v, got %v", expected, calls)
+		}
+	}
+}
+
+func TestApplierOrdering(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "ordering"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	var calls []string
+	record := func(applierName string) ApplierCtx {
+		return func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+			calls = append(calls, applierName)
+			return nil
+		}
+	}
+
+	mustAdd := func(err error) {
+		t.Helper()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mustAdd(confManager.AddNamedApplier(name, "http", record("http"), After("logging", "database")))
+	mustAdd(confManager.AddNamedApplier(name, "database", record("database")))
+	mustAdd(confManager.AddNamedApplier(name, "logging", record("logging"), Before("database")))
+	mustAdd(confManager.AddNamedApplier(name, "metrics", record("metrics"), Priority(10)))
+	mustAdd(confManager.AddNamedApplier(name, "tracing", record("tracing"), After("unknown")))
+
+	if err := confManager.AddNamedApplier(name, "logging", record("logging")); err == nil {
+		t.Errorf("expected an error on duplicated applier name")
+	}
+
+	if err := confManager.AddNamedApplier(name, "cycle", record("cycle"), After("http"), Before("logging")); err == nil {
+		t.Errorf("expected an error on dependency cycle")
+	}
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCalls(t, calls, []string{"metrics", "logging", "database", "http", "tracing"})
+}
+
+func TestParallelAppliers(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "parallel"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	// Both appliers wait for each other so they have to run in parallel
+	var barrier sync.WaitGroup
+	barrier.Add(2)
+
+	wait := func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+		barrier.Done()
+		barrier.Wait()
+		return nil
+	}
+
+	var last bool
+	final := func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+		last = true
+		return nil
+	}
+
+	for _, applierName := range []string{"a", "b"} {
+		if err := confManager.AddNamedApplier(name, applierName, wait); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := confManager.AddNamedApplier(name, "c", final, After("a", "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := confManager.MakeConfig(ctx, name, &MyConfig{}, WithParallelAppliers(), WithTimeouts(0, 5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !last {
+		t.Errorf("expected dependent applier to be run")
+	}
+}
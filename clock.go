@@ -0,0 +1,23 @@
+package config
+
+import (
+	"time"
+)
+
+// Clock is the interface that describes the source of time used by the
+// Manager for status timestamps, retry backoffs and polling intervals.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock backed by the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,963 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	header  = "// Code generated by qdconfig-gen. DO NOT EDIT."
+	libPath = "sylr.dev/libqd/config"
+	libName = "qdconfig"
+)
+
+// basicTypes lists the predeclared types which are copied by assignment and
+// compared with ==.
+var basicTypes = map[string]bool{
+	"bool": true, "string": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+	"byte": true, "rune": true,
+}
+
+// kind tells how the generated code handles a field.
+type kind int
+
+const (
+	// kindBasic is a basic type, a local type defined from a basic type or a
+	// time.Duration.
+	kindBasic kind = iota
+	// kindStruct is a struct declared in the package.
+	kindStruct
+	kindPtrBasic
+	kindPtrStruct
+	kindSliceBasic
+	kindSliceStruct
+	kindMapBasic
+	// kindOther is handled by the reflection based functions of the library.
+	kindOther
+)
+
+type structInfo struct {
+	name string
+	typ  *ast.StructType
+	file *ast.File
+}
+
+type field struct {
+	name string
+	// path is the name of the field in field paths, empty if the field is
+	// inlined in its parent.
+	path string
+	// hidden is true for fields which can not be set from the config file.
+	hidden bool
+	typ    ast.Expr
+	kind   kind
+	// elem is the type of the pointed or sliced value of basic kinds.
+	elem ast.Expr
+	// local is the name of the local struct of struct kinds.
+	local string
+	// basic is the underlying basic type of basic kinds.
+	basic string
+	tag   reflect.StructTag
+	file  *ast.File
+}
+
+type generator struct {
+	pkg     string
+	structs map[string]*structInfo
+	// basics maps the local types defined from basic types to these types.
+	basics    map[string]string
+	methods   map[string]map[string]bool
+	imports   map[string]string
+	envPrefix string
+	buf       bytes.Buffer
+}
+
+// generate parses the package in dir and returns the code generated for the
+// given types. The file being generated is ignored.
+func generate(dir string, output string, typeNames []string, envPrefix string) ([]byte, error) {
+	fset := token.NewFileSet()
+
+	filter := func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != filepath.Base(output)
+	}
+
+	pkgs, err := parser.ParseDir(fset, dir, filter, parser.ParseComments)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in `%s`, found %d", dir, len(pkgs))
+	}
+
+	g := &generator{
+		structs:   make(map[string]*structInfo),
+		basics:    make(map[string]string),
+		methods:   make(map[string]map[string]bool),
+		imports:   make(map[string]string),
+		envPrefix: envPrefix,
+	}
+
+	for name, pkg := range pkgs {
+		g.pkg = name
+
+		for _, file := range pkg.Files {
+			if isGenerated(file) {
+				continue
+			}
+
+			g.collect(file)
+		}
+	}
+
+	var roots []*structInfo
+
+	for _, name := range typeNames {
+		s, ok := g.structs[strings.TrimSpace(name)]
+
+		if !ok {
+			return nil, fmt.Errorf("struct type `%s` not found", name)
+		}
+
+		roots = append(roots, s)
+	}
+
+	structs := g.reachable(roots)
+
+	for _, s := range structs {
+		if err := g.genStruct(s); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range roots {
+		if err := g.genRoot(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return g.source()
+}
+
+// isGenerated returns true if file has been generated by qdconfig-gen.
+func isGenerated(file *ast.File) bool {
+	for _, c := range file.Comments {
+		if c.Pos() > file.Package {
+			break
+		}
+
+		if strings.HasPrefix(c.Text(), strings.TrimPrefix(header, "// ")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collect records the types and the methods declared in file.
+func (g *generator) collect(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+
+				if ts.Assign.IsValid() {
+					continue
+				}
+
+				switch t := ts.Type.(type) {
+				case *ast.StructType:
+					g.structs[ts.Name.Name] = &structInfo{ts.Name.Name, t, file}
+				case *ast.Ident:
+					if basicTypes[t.Name] {
+						g.basics[ts.Name.Name] = t.Name
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				continue
+			}
+
+			recv := d.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+
+			if ident, ok := recv.(*ast.Ident); ok {
+				if g.methods[ident.Name] == nil {
+					g.methods[ident.Name] = make(map[string]bool)
+				}
+
+				g.methods[ident.Name][d.Name.Name] = true
+			}
+		}
+	}
+}
+
+// hasMethod returns true if the local type typeName declares the method.
+func (g *generator) hasMethod(typeName string, method string) bool {
+	return g.methods[typeName][method]
+}
+
+// reachable returns the roots and the local structs they contain.
+func (g *generator) reachable(roots []*structInfo) []*structInfo {
+	var structs []*structInfo
+	seen := make(map[string]bool)
+	queue := append([]*structInfo{}, roots...)
+
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		if seen[s.name] {
+			continue
+		}
+
+		seen[s.name] = true
+		structs = append(structs, s)
+
+		for _, f := range g.fields(s) {
+			switch f.kind {
+			case kindStruct, kindPtrStruct, kindSliceStruct:
+				queue = append(queue, g.structs[f.local])
+			}
+		}
+	}
+
+	return structs
+}
+
+// fields returns the exported fields of s.
+func (g *generator) fields(s *structInfo) []field {
+	var fields []field
+
+	for _, f := range s.typ.Fields.List {
+		var tag reflect.StructTag
+
+		if f.Tag != nil {
+			t, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(t)
+		}
+
+		names := f.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{embeddedName(f.Type)}
+		}
+
+		for _, name := range names {
+			if name == nil || !name.IsExported() {
+				continue
+			}
+
+			fd := field{
+				name: name.Name,
+				typ:  f.Type,
+				tag:  tag,
+				file: s.file,
+			}
+
+			// Embedded structs which have not been explicitly named are inlined
+			fd.path, fd.hidden = pathName(tag)
+
+			if len(f.Names) > 0 && len(fd.path) == 0 {
+				fd.path = strings.ToLower(name.Name)
+			}
+
+			g.classify(&fd)
+			fields = append(fields, fd)
+		}
+	}
+
+	return fields
+}
+
+// embeddedName returns the name of an embedded field.
+func embeddedName(expr ast.Expr) *ast.Ident {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	}
+
+	return nil
+}
+
+// pathName returns the name given to the field in its yaml, json or toml tag,
+// as done by the library, and whether the field is hidden by one of these.
+func pathName(tag reflect.StructTag) (string, bool) {
+	hidden := false
+
+	for _, key := range []string{"yaml", "json", "toml"} {
+		n := strings.Split(tag.Get(key), ",")[0]
+
+		if n == "-" {
+			hidden = true
+		}
+
+		if len(n) > 0 && n != "-" {
+			return n, false
+		}
+	}
+
+	return "", hidden
+}
+
+// classify sets the kind of fd.
+func (g *generator) classify(fd *field) {
+	fd.kind = kindOther
+
+	switch t := fd.typ.(type) {
+	case *ast.StarExpr:
+		if basic := g.basicType(t.X, fd.file); len(basic) > 0 {
+			fd.kind, fd.basic, fd.elem = kindPtrBasic, basic, t.X
+		} else if s := g.localStruct(t.X); len(s) > 0 {
+			fd.kind, fd.local = kindPtrStruct, s
+		}
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return
+		}
+
+		if basic := g.basicType(t.Elt, fd.file); len(basic) > 0 {
+			fd.kind, fd.basic, fd.elem = kindSliceBasic, basic, t.Elt
+		} else if s := g.localStruct(t.Elt); len(s) > 0 {
+			fd.kind, fd.local = kindSliceStruct, s
+		}
+	case *ast.MapType:
+		if len(g.basicType(t.Key, fd.file)) > 0 && len(g.basicType(t.Value, fd.file)) > 0 {
+			fd.kind = kindMapBasic
+		}
+	default:
+		if basic := g.basicType(fd.typ, fd.file); len(basic) > 0 {
+			fd.kind, fd.basic = kindBasic, basic
+		} else if s := g.localStruct(fd.typ); len(s) > 0 {
+			fd.kind, fd.local = kindStruct, s
+		}
+	}
+}
+
+// basicType returns the underlying basic type of expr, or an empty string.
+func (g *generator) basicType(expr ast.Expr, file *ast.File) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if basicTypes[t.Name] {
+			return t.Name
+		}
+
+		return g.basics[t.Name]
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok && t.Sel.Name == "Duration" && importPath(file, x.Name) == "time" {
+			return "time.Duration"
+		}
+	}
+
+	return ""
+}
+
+// localStruct returns the name of the struct declared in the package which
+// expr designates, or an empty string.
+func (g *generator) localStruct(expr ast.Expr) string {
+	if ident, ok := expr.(*ast.Ident); ok {
+		if _, ok := g.structs[ident.Name]; ok {
+			return ident.Name
+		}
+	}
+
+	return ""
+}
+
+// hasExportedFields returns true if the local struct name has exported fields.
+func (g *generator) hasExportedFields(name string) bool {
+	return len(g.fields(g.structs[name])) > 0
+}
+
+// typeString returns the source of the type expr, declared in file, and
+// records the imports it requires.
+func (g *generator) typeString(expr ast.Expr, file *ast.File) string {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				if p := importPath(file, x.Name); len(p) > 0 {
+					g.imports[x.Name] = p
+				}
+			}
+
+			return false
+		}
+
+		return true
+	})
+
+	return types.ExprString(expr)
+}
+
+// importPath returns the path of the package imported as name in file.
+func importPath(file *ast.File, name string) string {
+	for _, imp := range file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+
+		if imp.Name != nil && imp.Name.Name == name {
+			return p
+		}
+
+		if imp.Name == nil && path.Base(p) == name {
+			return p
+		}
+	}
+
+	return ""
+}
+
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format+"\n", args...)
+}
+
+// fieldPath returns the expression of the path of f in the generated code.
+func fieldPath(f field) string {
+	if len(f.path) == 0 {
+		return "path"
+	}
+
+	return fmt.Sprintf("%s.JoinPath(path, %q)", libName, f.path)
+}
+
+// genStruct generates the methods of a struct, root or not.
+func (g *generator) genStruct(s *structInfo) error {
+	fields := g.fields(s)
+
+	g.genDeepCopy(s, fields)
+
+	if err := g.genDefaults(s, fields); err != nil {
+		return err
+	}
+
+	g.genValidate(s, fields)
+	g.genDiff(s, fields)
+
+	return nil
+}
+
+func (g *generator) genDeepCopy(s *structInfo, fields []field) {
+	if !g.hasMethod(s.name, "DeepCopyInto") {
+		g.p("// DeepCopyInto copies the receiver into out. in must be non-nil.")
+		g.p("func (in *%s) DeepCopyInto(out *%s) {", s.name, s.name)
+		g.p("*out = *in")
+
+		for _, f := range fields {
+			switch f.kind {
+			case kindPtrBasic:
+				g.p("if in.%s != nil {", f.name)
+				g.p("v := *in.%s", f.name)
+				g.p("out.%s = &v", f.name)
+				g.p("}")
+			case kindSliceBasic:
+				g.p("if in.%s != nil {", f.name)
+				g.p("out.%s = make(%s, len(in.%s))", f.name, g.typeString(f.typ, f.file), f.name)
+				g.p("copy(out.%s, in.%s)", f.name, f.name)
+				g.p("}")
+			case kindMapBasic:
+				g.p("if in.%s != nil {", f.name)
+				g.p("out.%s = make(%s, len(in.%s))", f.name, g.typeString(f.typ, f.file), f.name)
+				g.p("for k, v := range in.%s {", f.name)
+				g.p("out.%s[k] = v", f.name)
+				g.p("}")
+				g.p("}")
+			case kindStruct:
+				g.p("in.%s.DeepCopyInto(&out.%s)", f.name, f.name)
+			case kindPtrStruct:
+				g.p("out.%s = in.%s.DeepCopy()", f.name, f.name)
+			case kindSliceStruct:
+				g.p("if in.%s != nil {", f.name)
+				g.p("out.%s = make(%s, len(in.%s))", f.name, g.typeString(f.typ, f.file), f.name)
+				g.p("for i := range in.%s {", f.name)
+				g.p("in.%s[i].DeepCopyInto(&out.%s[i])", f.name, f.name)
+				g.p("}")
+				g.p("}")
+			case kindOther:
+				g.p("if v, ok := %s.DeepCopyValue(in.%s).(%s); ok {", libName, f.name, g.typeString(f.typ, f.file))
+				g.p("out.%s = v", f.name)
+				g.p("}")
+			}
+		}
+
+		g.p("}\n")
+	}
+
+	if !g.hasMethod(s.name, "DeepCopy") {
+		g.p("// DeepCopy returns a deep copy of the receiver.")
+		g.p("func (in *%s) DeepCopy() *%s {", s.name, s.name)
+		g.p("if in == nil {")
+		g.p("return nil")
+		g.p("}")
+		g.p("out := new(%s)", s.name)
+		g.p("in.DeepCopyInto(out)")
+		g.p("return out")
+		g.p("}\n")
+	}
+}
+
+func (g *generator) genDefaults(s *structInfo, fields []field) error {
+	g.p("func (c *%s) qdconfigApplyDefaults(path string) error {", s.name)
+
+	for _, f := range fields {
+		// go-flags sets the defaults of command line options itself
+		if tag, ok := f.tag.Lookup("default"); ok && !isFlag(f) {
+			if err := g.genDefault(f, tag); err != nil {
+				return fmt.Errorf("invalid default value of `%s.%s`: %v", s.name, f.name, err)
+			}
+		}
+
+		switch f.kind {
+		case kindStruct:
+			g.p("if err := c.%s.qdconfigApplyDefaults(%s); err != nil {", f.name, fieldPath(f))
+			g.p("return err")
+			g.p("}")
+		case kindPtrStruct:
+			g.p("if c.%s != nil {", f.name)
+			g.p("if err := c.%s.qdconfigApplyDefaults(%s); err != nil {", f.name, fieldPath(f))
+			g.p("return err")
+			g.p("}")
+			g.p("}")
+		case kindOther:
+			// Structs of other packages are walked by reflection
+			g.p("if err := %s.DefaultValue(%s, &c.%s); err != nil {", libName, fieldPath(f), f.name)
+			g.p("return err")
+			g.p("}")
+		}
+	}
+
+	g.p("return nil")
+	g.p("}\n")
+
+	return nil
+}
+
+// isFlag returns true if go-flags considers f as a command line option.
+func isFlag(f field) bool {
+	for _, key := range []string{"short", "long", "ini-name"} {
+		if len(f.tag.Get(key)) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// genDefault generates the code setting the default value of a field. Values
+// of basic types are parsed at generation time, the others are handed to
+// qdconfig.SetDefault.
+func (g *generator) genDefault(f field, tag string) error {
+	basic := f.basic
+	if len(basic) > 0 && !g.isPlainBasic(f) {
+		basic = ""
+	}
+
+	switch f.kind {
+	case kindBasic:
+		if lit, err := literal(basic, tag); err == nil && len(lit) > 0 {
+			g.p("if c.%s == %s {", f.name, zero(basic))
+			g.p("c.%s = %s", f.name, lit)
+			g.p("}")
+			return nil
+		} else if err != nil {
+			return err
+		}
+	case kindPtrBasic:
+		if lit, err := literal(basic, tag); err == nil && len(lit) > 0 {
+			g.p("if c.%s == nil {", f.name)
+			g.p("v := %s(%s)", g.typeString(f.elem, f.file), lit)
+			g.p("c.%s = &v", f.name)
+			g.p("}")
+			return nil
+		} else if err != nil {
+			return err
+		}
+	case kindSliceBasic:
+		if len(basic) > 0 {
+			var parts, lits []string
+			if len(tag) > 0 {
+				parts = strings.Split(tag, ",")
+			}
+
+			for _, part := range parts {
+				lit, err := literal(basic, strings.TrimSpace(part))
+
+				if err != nil {
+					return err
+				}
+
+				lits = append(lits, lit)
+			}
+
+			g.p("if c.%s == nil {", f.name)
+			g.p("c.%s = %s{%s}", f.name, g.typeString(f.typ, f.file), strings.Join(lits, ", "))
+			g.p("}")
+			return nil
+		}
+	}
+
+	g.p("if err := %s.SetDefault(%s, &c.%s, %q); err != nil {", libName, fieldPath(f), f.name, tag)
+	g.p("return err")
+	g.p("}")
+
+	return nil
+}
+
+// isPlainBasic returns true if the values of the field are not parsed by an
+// UnmarshalText method.
+func (g *generator) isPlainBasic(f field) bool {
+	typ := f.typ
+	if f.kind != kindBasic {
+		typ = f.elem
+	}
+
+	return !g.hasMethod(types.ExprString(typ), "UnmarshalText")
+}
+
+// literal returns the Go literal of the default value s of the given basic
+// type, or an empty string if the type is not handled. Complex numbers are
+// rejected.
+func literal(basic string, s string) (string, error) {
+	switch basic {
+	case "string":
+		return strconv.Quote(s), nil
+	case "bool":
+		b, err := strconv.ParseBool(s)
+		return strconv.FormatBool(b), err
+	case "time.Duration":
+		d, err := time.ParseDuration(s)
+		return strconv.FormatInt(int64(d), 10), err
+	case "int", "int8", "int16", "int32", "int64", "rune":
+		i, err := strconv.ParseInt(s, 0, bits(basic))
+		return strconv.FormatInt(i, 10), err
+	case "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte":
+		u, err := strconv.ParseUint(s, 0, bits(basic))
+		return strconv.FormatUint(u, 10), err
+	case "float32", "float64":
+		f, err := strconv.ParseFloat(s, bits(basic))
+		return strconv.FormatFloat(f, 'g', -1, bits(basic)), err
+	case "complex64", "complex128":
+		// Not supported by qdconfig.SetDefault either
+		return "", fmt.Errorf("unsupported type %s", basic)
+	}
+
+	return "", nil
+}
+
+// bits returns the size of a numeric basic type.
+func bits(basic string) int {
+	switch basic {
+	case "int8", "uint8", "byte":
+		return 8
+	case "int16", "uint16":
+		return 16
+	case "int32", "uint32", "rune", "float32":
+		return 32
+	}
+
+	return 64
+}
+
+// zero returns the zero value of a basic type.
+func zero(basic string) string {
+	switch basic {
+	case "string":
+		return `""`
+	case "bool":
+		return "false"
+	}
+
+	return "0"
+}
+
+func (g *generator) genValidate(s *structInfo, fields []field) {
+	g.p("func (c *%s) qdconfigValidateTags(path string) []error {", s.name)
+	g.p("var errs []error")
+
+	for _, f := range fields {
+		if tag := f.tag.Get("validate"); len(tag) > 0 && tag != "-" {
+			g.p("errs = append(errs, %s.ValidateField(%s, c.%s, %q)...)", libName, fieldPath(f), f.name, tag)
+		}
+
+		switch f.kind {
+		case kindStruct:
+			g.p("errs = append(errs, c.%s.qdconfigValidateTags(%s)...)", f.name, fieldPath(f))
+		case kindPtrStruct:
+			g.p("if c.%s != nil {", f.name)
+			g.p("errs = append(errs, c.%s.qdconfigValidateTags(%s)...)", f.name, fieldPath(f))
+			g.p("}")
+		case kindSliceStruct:
+			g.p("for i := range c.%s {", f.name)
+			g.p("errs = append(errs, c.%s[i].qdconfigValidateTags(%s.IndexPath(%s, i))...)", f.name, libName, fieldPath(f))
+			g.p("}")
+		case kindOther:
+			g.p("errs = append(errs, %s.ValidateValue(%s, c.%s)...)", libName, fieldPath(f), f.name)
+		}
+	}
+
+	g.p("return errs")
+	g.p("}\n")
+}
+
+func (g *generator) genDiff(s *structInfo, fields []field) {
+	g.p("func (c *%s) qdconfigDiff(old *%s, path string) %s.Changes {", s.name, s.name, libName)
+	g.p("var changes %s.Changes", libName)
+
+	for _, f := range fields {
+		switch {
+		case f.kind == kindBasic:
+			g.p("if old.%s != c.%s {", f.name, f.name)
+			g.p("changes = append(changes, %s.Change{Path: %s, Type: %s.ChangeModified, Old: old.%s, New: c.%s})", libName, fieldPath(f), libName, f.name, f.name)
+			g.p("}")
+		case f.kind == kindStruct && g.hasExportedFields(f.local):
+			g.p("changes = append(changes, c.%s.qdconfigDiff(&old.%s, %s)...)", f.name, f.name, fieldPath(f))
+		default:
+			g.p("changes = append(changes, %s.DiffValues(%s, old.%s, c.%s)...)", libName, fieldPath(f), f.name, f.name)
+		}
+	}
+
+	g.p("return changes")
+	g.p("}\n")
+}
+
+// genRoot generates the methods and the variables of a config type.
+func (g *generator) genRoot(s *structInfo) error {
+	name := s.name
+
+	if !g.hasMethod(name, "DeepCopyConfig") {
+		g.p("// DeepCopyConfig returns a deep copy of the receiver.")
+		g.p("func (in *%s) DeepCopyConfig() %s.Config {", name, libName)
+		g.p("if c := in.DeepCopy(); c != nil {")
+		g.p("return c")
+		g.p("}")
+		g.p("return nil")
+		g.p("}\n")
+	}
+
+	if !g.hasMethod(name, "ConfigFile") {
+		file := configFileField(g.fields(s))
+
+		if len(file) == 0 {
+			return fmt.Errorf("no config file field found in `%s`, tag a string field with `long:\"config\"` or declare a ConfigFile method", name)
+		}
+
+		g.p("// ConfigFile returns the path of the configuration file.")
+		g.p("func (c *%s) ConfigFile() string {", name)
+		g.p("return c.%s", file)
+		g.p("}\n")
+	}
+
+	if !g.hasMethod(name, "ApplyDefaults") {
+		g.p("// ApplyDefaults sets the empty fields to the value of their `default` struct")
+		g.p("// tag.")
+		g.p("func (c *%s) ApplyDefaults() error {", name)
+		g.p("return c.qdconfigApplyDefaults(\"\")")
+		g.p("}\n")
+	}
+
+	if !g.hasMethod(name, "ValidateTags") {
+		g.p("// ValidateTags checks the fields against the rules of their `validate` struct")
+		g.p("// tag.")
+		g.p("func (c *%s) ValidateTags() []error {", name)
+		g.p("return c.qdconfigValidateTags(\"\")")
+		g.p("}\n")
+	}
+
+	if !g.hasMethod(name, "DiffConfig") {
+		g.p("// DiffConfig returns the changes from old to the receiver.")
+		g.p("func (c *%s) DiffConfig(old %s.Config) %s.Changes {", name, libName, libName)
+		g.p("o, ok := old.(*%s)", name)
+		g.p("if !ok || o == nil || c == nil {")
+		g.p("return %s.DiffValues(\"\", old, c)", libName)
+		g.p("}")
+		g.p("return c.qdconfigDiff(o, \"\")")
+		g.p("}\n")
+	}
+
+	return g.genEnvVars(s)
+}
+
+// configFileField returns the name of the string field holding the path of the
+// configuration file.
+func configFileField(fields []field) string {
+	for _, f := range fields {
+		if f.tag.Get("long") == "config" && types.ExprString(f.typ) == "string" {
+			return f.name
+		}
+	}
+
+	for _, f := range fields {
+		if f.name == "File" && types.ExprString(f.typ) == "string" {
+			return f.name
+		}
+	}
+
+	return ""
+}
+
+// genEnvVars generates the table mapping environment variable names to field
+// paths. Names are taken from `env` struct tags or derived from the paths.
+func (g *generator) genEnvVars(s *structInfo) error {
+	vars := make(map[string]string)
+	var names []string
+
+	var walk func(s *structInfo, parent string, seen map[string]bool) error
+	walk = func(s *structInfo, parent string, seen map[string]bool) error {
+		if seen[s.name] {
+			return nil
+		}
+
+		seen[s.name] = true
+		defer delete(seen, s.name)
+
+		for _, f := range g.fields(s) {
+			env := f.tag.Get("env")
+
+			if env == "-" || (f.hidden && len(env) == 0) {
+				continue
+			}
+
+			fpath := parent
+			if len(f.path) > 0 {
+				fpath = joinPath(parent, f.path)
+			}
+
+			if f.kind == kindStruct || f.kind == kindPtrStruct {
+				if err := walk(g.structs[f.local], fpath, seen); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if len(env) == 0 {
+				env = g.envPrefix + envName(fpath)
+			}
+
+			if other, ok := vars[env]; ok {
+				return fmt.Errorf("environment variable `%s` used by both `%s` and `%s`", env, other, fpath)
+			}
+
+			vars[env] = fpath
+			names = append(names, env)
+		}
+
+		return nil
+	}
+
+	if err := walk(s, "", make(map[string]bool)); err != nil {
+		return err
+	}
+
+	sort.Strings(names)
+
+	g.p("// %sEnvVars maps the names of environment variables to the paths of", s.name)
+	g.p("// the fields of %s they set.", s.name)
+	g.p("var %sEnvVars = map[string]string{", s.name)
+
+	for _, name := range names {
+		g.p("%q: %q,", name, vars[name])
+	}
+
+	g.p("}\n")
+
+	return nil
+}
+
+func joinPath(parent string, name string) string {
+	if len(parent) == 0 {
+		return name
+	}
+
+	return parent + "." + name
+}
+
+// envName turns a field path into an environment variable name.
+func envName(fpath string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+
+		return '_'
+	}, fpath)
+}
+
+// source assembles and formats the generated file.
+func (g *generator) source() ([]byte, error) {
+	var out bytes.Buffer
+
+	fmt.Fprintf(&out, "%s\n\npackage %s\n\nimport (\n", header, g.pkg)
+
+	g.imports[libName] = libPath
+
+	names := make([]string, 0, len(g.imports))
+	for name := range g.imports {
+		names = append(names, name)
+	}
+
+	// Standard library packages first
+	std := func(p string) bool { return !strings.Contains(strings.Split(p, "/")[0], ".") }
+
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := g.imports[names[i]], g.imports[names[j]]
+
+		if std(pi) != std(pj) {
+			return std(pi)
+		}
+
+		return pi < pj
+	})
+
+	for i, name := range names {
+		if i > 0 && std(g.imports[names[i-1]]) && !std(g.imports[name]) {
+			fmt.Fprintf(&out, "\n")
+		}
+
+		if path.Base(g.imports[name]) == name {
+			fmt.Fprintf(&out, "%q\n", g.imports[name])
+		} else {
+			fmt.Fprintf(&out, "%s %q\n", name, g.imports[name])
+		}
+	}
+
+	fmt.Fprintf(&out, ")\n\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, out.Bytes())
+	}
+
+	return src, nil
+}
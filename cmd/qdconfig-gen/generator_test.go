@@ -0,0 +1,144 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("testdata", "conf")
+
+	src, err := generate(dir, filepath.Join(dir, "conf_qdconfig.go"), []string{"Conf"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+
+	file, err := parser.ParseFile(fset, "conf_qdconfig.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	typeCheck(t, fset, dir, file)
+
+	if !isGenerated(file) {
+		t.Errorf("generated file should start with %q", header)
+	}
+
+	methods := make(map[string]bool)
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil {
+			recv := fn.Recv.List[0].Type.(*ast.StarExpr).X.(*ast.Ident)
+			methods[recv.Name+"."+fn.Name.Name] = true
+		}
+	}
+
+	for _, method := range []string{
+		"Conf.DeepCopyInto", "Conf.DeepCopy", "Conf.DeepCopyConfig", "Conf.ConfigFile",
+		"Conf.ApplyDefaults", "Conf.ValidateTags", "Conf.DiffConfig",
+		"Database.DeepCopyInto", "Database.DeepCopy", "Base.DeepCopy",
+	} {
+		if !methods[method] {
+			t.Errorf("method %s has not been generated", method)
+		}
+	}
+
+	if methods["Base.DeepCopyInto"] {
+		t.Errorf("methods declared in the package should not be generated")
+	}
+
+	// Ignore the alignment made by gofmt
+	code := strings.Join(strings.Fields(string(src)), " ")
+
+	for _, expected := range []string{
+		// Defaults are parsed at generation time
+		"c.Port = 8080",
+		"c.Timeout = 5000000000",
+		`c.Tags = []string{"a", "b"}`,
+		"v := float64(0.5)",
+		// Paths of inlined, nested and sliced structs
+		"c.Base.qdconfigValidateTags(path)",
+		`c.Database.qdconfigValidateTags(qdconfig.JoinPath(path, "database"))`,
+		`qdconfig.IndexPath(qdconfig.JoinPath(path, "replicas"), i)`,
+		// Types which are not handled fall back to reflection
+		"qdconfig.DeepCopyValue(in.Networks).([]net.IPNet)",
+		`qdconfig.DefaultValue(qdconfig.JoinPath(path, "networks"), &c.Networks)`,
+		`"net"`,
+		// Environment variables
+		`"LOG_LEVEL": "level"`,
+		`"CACHE_HOST": "cache.host"`,
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code should contain %s", expected)
+		}
+	}
+
+	if strings.Contains(code, `"conf.yaml"`) {
+		t.Errorf("defaults of command line options should be left to go-flags")
+	}
+
+	if strings.Contains(code, `"INTERNAL"`) {
+		t.Errorf("fields which can not be set from the config file should not have environment variables")
+	}
+
+	// Generation is reproducible
+	again, err := generate(dir, filepath.Join(dir, "other_qdconfig.go"), []string{"Conf"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(again) != string(src) {
+		t.Errorf("generation should be reproducible")
+	}
+}
+
+// typeCheck type-checks the package in dir along with the generated file, the
+// library being imported from its sources.
+func typeCheck(t *testing.T, fset *token.FileSet, dir string, generated *ast.File) {
+	t.Helper()
+
+	pkgs, err := parser.ParseDir(fset, dir, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*ast.File{generated}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			files = append(files, file)
+		}
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+
+	if _, err := conf.Check(generated.Name.Name, fset, files, nil); err != nil {
+		t.Errorf("generated code does not type-check: %v", err)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := filepath.Join("testdata", "invalid")
+	output := filepath.Join(dir, "invalid_qdconfig.go")
+
+	tests := map[string]string{
+		"Unknown":        "struct type `Unknown` not found",
+		"InvalidDefault": "invalid default value of `InvalidDefault.Port`",
+		"ComplexDefault": "invalid default value of `ComplexDefault.Phase`: unsupported type complex128",
+		"NoFile":         "no config file field found in `NoFile`",
+	}
+
+	for typeName, expected := range tests {
+		_, err := generate(dir, output, []string{typeName}, "")
+
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected error %q, got %v", typeName, expected, err)
+		}
+	}
+}
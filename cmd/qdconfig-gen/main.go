@@ -0,0 +1,73 @@
+// Command qdconfig-gen generates the boilerplate of configuration structs
+// managed by sylr.dev/libqd/config so that the Manager does not have to walk
+// them by reflection. It is meant to be used with go generate:
+//
+//	//go:generate go run sylr.dev/libqd/config/cmd/qdconfig-gen -type MyAppConfiguration
+//
+// For each type given, and for the structs of the package it contains, it
+// generates:
+//
+//	DeepCopyInto, DeepCopy and DeepCopyConfig
+//	ConfigFile, returning the field tagged `long:"config"` or named File
+//	ApplyDefaults, setting the values of `default` struct tags
+//	ValidateTags, checking the rules of `validate` struct tags
+//	DiffConfig, computing the changes from another configuration
+//	<Type>EnvVars, mapping environment variable names to field paths
+//
+// Methods already declared in the package are not generated, which allows to
+// override any of them. Config types sharing nested structs must be given to
+// the same invocation so that the methods of these structs are generated once.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma separated list of config struct type names (mandatory)")
+	output := flag.String("output", "", "output file name (default <type>_qdconfig.go)")
+	envPrefix := flag.String("env-prefix", "", "prefix of the generated environment variable names")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: qdconfig-gen -type T [flags] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if len(*typeNames) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	types := strings.Split(*typeNames, ",")
+
+	if len(*output) == 0 {
+		*output = strings.ToLower(types[0]) + "_qdconfig.go"
+	}
+
+	filename := *output
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(dir, filename)
+	}
+
+	src, err := generate(dir, filename, types, *envPrefix)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "qdconfig-gen: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := ioutil.WriteFile(filename, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "qdconfig-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
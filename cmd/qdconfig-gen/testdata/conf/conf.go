@@ -0,0 +1,38 @@
+package conf
+
+import (
+	"net"
+	"time"
+)
+
+type Level int
+
+type Conf struct {
+	Base
+
+	File     string            `long:"config" default:"conf.yaml"`
+	Name     string            `yaml:"name" default:"app" validate:"required"`
+	Port     int               `yaml:"port" default:"8080" validate:"min=1,max=65535"`
+	Timeout  time.Duration     `yaml:"timeout" default:"5s"`
+	Ratio    *float64          `yaml:"ratio" default:"0.5"`
+	Level    Level             `yaml:"level" env:"LOG_LEVEL"`
+	Tags     []string          `yaml:"tags" default:"a, b"`
+	Labels   map[string]string `yaml:"labels"`
+	Database Database          `yaml:"database"`
+	Cache    *Database         `yaml:"cache"`
+	Replicas []Database        `yaml:"replicas"`
+	Networks []net.IPNet       `yaml:"networks"`
+	Extra    interface{}       `yaml:"extra"`
+	Internal int               `yaml:"-"`
+
+	private int
+}
+
+type Base struct {
+	Debug bool `yaml:"debug"`
+}
+
+type Database struct {
+	Host string `yaml:"host" validate:"required"`
+	Port int    `yaml:"port" default:"5432"`
+}
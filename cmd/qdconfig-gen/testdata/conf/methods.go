@@ -0,0 +1,6 @@
+package conf
+
+// DeepCopyInto is declared so that qdconfig-gen does not generate it.
+func (in *Base) DeepCopyInto(out *Base) {
+	*out = *in
+}
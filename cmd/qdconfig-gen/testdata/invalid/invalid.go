@@ -0,0 +1,15 @@
+package invalid
+
+type InvalidDefault struct {
+	File string `long:"config"`
+	Port int    `default:"eighty"`
+}
+
+type ComplexDefault struct {
+	File  string     `long:"config"`
+	Phase complex128 `default:"1+2i"`
+}
+
+type NoFile struct {
+	Path string
+}
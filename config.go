@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"fmt"
+	"runtime"
 	"sync"
 	"time"
 
@@ -10,15 +11,15 @@ import (
 )
 
 var (
-	manager *Manager
+	manager     *Manager
+	managerOnce sync.Once
 )
 
 // -----------------------------------------------------------------------------
 
-// Config is an interface describing functions needed by this module.
+// Config is an interface describing functions needed by this module. Configs
+// are copied by reflection unless they implement DeepCopier.
 type Config interface {
-	// DeepCopyConfig returns a copy of the current struct.
-	DeepCopyConfig() Config
 	// ConfigFile returns the path of the config file.
 	ConfigFile() string
 }
@@ -27,34 +28,108 @@ type Config interface {
 type Chan chan Config
 
 // Validator is a function type which will ensure that the content of the config
-// file is valid and can be applied
+// file is valid and can be applied. Validators are run after the rules declared
+// in the `validate` struct tags of the config have been checked.
 type Validator func(currentConfig Config, newConfig Config) []error
 
+// InfoValidator is a Validator which also receives details about the reload.
+type InfoValidator func(currentConfig Config, newConfig Config, info *ReloadInfo) []error
+
 // Applier is a function type which will apply the new configuration
 type Applier func(currentConfig Config, newConfig Config) error
 
+// InfoApplier is an Applier which also receives details about the reload,
+// including the changes between the current and the new configuration.
+type InfoApplier func(currentConfig Config, newConfig Config, info *ReloadInfo) error
+
+// ValidatorCtx is a Validator which receives a context and details about the
+// reload. The context is canceled when the validation stage times out, see
+// WithTimeouts, and carries the ReloadInfo, see ReloadInfoFromContext.
+type ValidatorCtx func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) []error
+
+// ApplierCtx is an Applier which receives a context and details about the
+// reload. The context is canceled when the apply stage times out, see
+// WithTimeouts, and carries the ReloadInfo, see ReloadInfoFromContext.
+type ApplierCtx func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error
+
+// ReloadInfo gives details about a reload.
+type ReloadInfo struct {
+	// Name is the name of the configuration being reloaded.
+	Name interface{}
+	// Reason tells why the configuration is being reloaded.
+	Reason Reason
+	// Changes lists the differences between the current and the new
+	// configuration. It is computed once per reload.
+	Changes Changes
+	// Warnings lists the warnings and notices returned by the validators,
+	// see Warn. It is only set once validators have been run.
+	Warnings []error
+}
+
+// ReloadEvent is sent to the channels returned by NewReloadChan when a new
+// configuration has been applied.
+type ReloadEvent struct {
+	*ReloadInfo
+	// Config is the new configuration.
+	Config Config
+}
+
+// ReloadChan is a channel within which reload events will be sent.
+type ReloadChan chan *ReloadEvent
+
 // -----------------------------------------------------------------------------
 
-// GetManager returns the Manager that will give you access to all your configs.
+// GetManager returns a process wide Manager that will give you access to all
+// your configs. The logger is only used by the first call, use NewManager to
+// create independent Managers.
 func GetManager(logger Logger) *Manager {
-	if manager == nil {
-		manager = &Manager{
-			logger: logger,
-		}
-	}
+	managerOnce.Do(func() {
+		manager = NewManager(WithLogger(logger))
+	})
 
 	return manager
 }
 
+// NewManager returns a new Manager configured with the given options.
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{
+		logger: nopLogger{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
 // Manager is a struct that stores configuration watchers and the chans used to
 // broadcasts new configurations when configurations files are updated.
 type Manager struct {
 	logger     Logger
 	watchers   map[interface{}]*watcher
 	chans      map[interface{}][]Chan
-	validators map[interface{}][]Validator
-	appliers   map[interface{}][]Applier
+	validators map[interface{}][]registeredValidator
+	appliers   map[interface{}][]registeredApplier
 	mu         sync.RWMutex
+
+	// applierSeq numbers the appliers in registration order
+	applierSeq int
+
+	mutators      map[interface{}][]Mutator
+	reloadChans   map[interface{}][]ReloadChan
+	subscriptions map[interface{}][]*subscription
+	views         map[interface{}][]*View
+
+	// closed is true once the Manager has been shut down
+	closed bool
+	done   chan struct{}
+
+	clock         Clock
+	fs            FS
+	parsers       map[string]Parser
+	defaultFormat string
+	reloadPolicy  *ReloadPolicy
 }
 
 // GetConfig returns an existing configuration, nil otherwise.
@@ -83,67 +158,178 @@ func (m *Manager) GetWatcher(name interface{}) *watcher {
 
 // MakeConfig creates a named configuration. If config.ConfigFile() returns anything
 // but an empty string it will spawn a goroutine which will watch for changes
-// in the file. The file does not have to exists, it can be created after the
-// config has been created.
-func (m *Manager) MakeConfig(ctx context.Context, name interface{}, config Config) error {
-	var err error
-
+// in the file. The file has to exist unless the WithOptionalFile option is
+// given, in which case it can be created after the config has been created.
+//
+// Every load starts from a copy of config as given to MakeConfig, whose empty
+// fields are set to their defaults (see Defaulter), and then loads the
+// command line and the config file on top of it. A value removed from the
+// config file is thus reset to its default on reload.
+func (m *Manager) MakeConfig(ctx context.Context, name interface{}, config Config, opts ...ConfigOption) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+
+	if m.closed {
+		m.mu.Unlock()
+		return ErrManagerClosed
+	}
 
 	if m.watchers == nil {
 		m.watchers = make(map[interface{}]*watcher)
 	}
 
 	if _, ok := m.watchers[name]; ok {
+		m.mu.Unlock()
 		return fmt.Errorf("configuration `%v` already exists", name)
 	}
 
-	fsnotify, err := fsnotify.NewWatcher()
+	policy := m.policy()
+
+	w := &watcher{
+		manager:          m,
+		logger:           m.logger,
+		name:             name,
+		initialConfig:    config,
+		baseConfig:       copyConfig(config),
+		trigger:          make(chan struct{}, 1),
+		executorDone:     make(chan struct{}),
+		stopped:          make(chan struct{}),
+		done:             make(chan struct{}),
+		fileLoaded:       make(chan struct{}),
+		restartPolicy:    policy.Restart,
+		retryPolicy:      policy.Retry,
+		validateTimeout:  policy.ValidateTimeout,
+		warningsAsErrors: policy.WarningsAsErrors,
+		applyTimeout:     policy.ApplyTimeout,
+		fs:               m.fs,
+		clock:            m.clock,
+		parsers:          builtinParsers(),
+	}
 
-	if err != nil {
-		return err
+	if w.fs == nil {
+		w.fs = osFS{}
+	}
+
+	if w.clock == nil {
+		w.clock = systemClock{}
 	}
 
-	m.watchers[name] = &watcher{fsnotify, m, m.logger, name, config}
+	for ext, parser := range m.parsers {
+		w.parsers[ext] = parser
+	}
 
-	// Load config from cli args and then from config file if exists
-	err = m.watchers[name].loadConfig(config)
+	w.defaultParser = w.parsers[m.defaultFormat]
 
-	if err != nil {
-		return err
+	for _, opt := range opts {
+		opt(w)
 	}
 
-	// Execute validators
-	errs := m.runValidators(name, nil, config)
+	// The watcher stops when ctx is done or when the config is deleted
+	ctx, w.cancel = context.WithCancel(ctx)
 
-	if len(errs) > 0 {
-		for _, err := range errs {
-			m.logger.Errorf("Error while validating new conf: %v", err)
-		}
+	m.watchers[name] = w
+	m.mu.Unlock()
 
-		err = fmt.Errorf("new configuration not applied because error(s) have been found")
-		return err
-	}
+	go w.runExecutor(ctx)
 
-	// Execute appliers
-	err = m.runAppliers(name, nil, config)
+	// Load, validate and apply the configuration
+	err := w.reload(ctx, ReasonInitial)
 
 	if err != nil {
+		// The configuration may have been deleted and created again meanwhile
+		m.mu.Lock()
+		if m.watchers[name] == w {
+			delete(m.watchers, name)
+		}
+		m.mu.Unlock()
+		w.cancel()
+
+		// The watching go routine has not been started
+		close(w.done)
+
 		return err
 	}
 
 	// Spawn a goroutine to watch the config file it has been defined
 	if len(config.ConfigFile()) > 0 {
-		go m.watchers[name].watchConfigFile(ctx)
+		// Fall back to polling if filesystem notifications are not available
+		if !w.polling {
+			w.fsWatcher, err = fsnotify.NewWatcher()
 
-		// Sleep a bit to let the watchConfigFile go routine the time to watch
-		// the configuration file it is supposed to.
-		// nosemgrep
-		time.Sleep(time.Millisecond)
+			if err != nil {
+				m.logger.Warnf("fsnotify: %v, falling back to polling", err)
+			}
+		}
+
+		watch := w.startWatching()
+
+		go func() {
+			defer close(w.done)
+			watch(ctx)
+		}()
+	} else {
+		close(w.done)
+	}
+
+	return nil
+}
+
+// WaitForFile blocks until a configuration loaded from the config file of the
+// named configuration has been applied. It is meant to be used along with the
+// WithOptionalFile option when the file is written by another process (e.g. a
+// sidecar) after the program has started. A timeout of 0 means no timeout.
+func (m *Manager) WaitForFile(ctx context.Context, name interface{}, timeout time.Duration) error {
+	w := m.GetWatcher(name)
+
+	if w == nil {
+		return fmt.Errorf("configuration `%v` does not exist", name)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	select {
+	case <-w.fileLoaded:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for config file of `%v`: %w", name, ctx.Err())
+	}
+}
+
+// setConfig replaces the current configuration.
+func (m *Manager) setConfig(name interface{}, config Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if w, ok := m.watchers[name]; ok {
+		w.config = config
 	}
+}
+
+// RestartRequired returns the paths of the fields tagged with `reload:"restart"`
+// which have been changed in the configuration file since the program started.
+func (m *Manager) RestartRequired(name interface{}) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if w, ok := m.watchers[name]; ok && len(w.restartRequired) > 0 {
+		paths := make([]string, len(w.restartRequired))
+		copy(paths, w.restartRequired)
+		return paths
+	}
+
+	return nil
+}
+
+func (m *Manager) setRestartRequired(name interface{}, paths []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	return err
+	if w, ok := m.watchers[name]; ok {
+		w.restartRequired = paths
+	}
 }
 
 // NewConfigChan returns a channel that will be used to send new configurations
@@ -155,6 +341,24 @@ func (m *Manager) NewConfigChan(name interface{}) Chan {
 	return c
 }
 
+// NewReloadChan returns a channel that will be used to send reload events,
+// which carry the new configuration and the details of the reload, when a new
+// configuration has been applied.
+func (m *Manager) NewReloadChan(name interface{}) ReloadChan {
+	c := make(ReloadChan)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.reloadChans == nil {
+		m.reloadChans = make(map[interface{}][]ReloadChan)
+	}
+
+	m.reloadChans[name] = append(m.reloadChans[name], c)
+
+	return c
+}
+
 func (m *Manager) registerChan(name interface{}, c Chan) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -166,14 +370,64 @@ func (m *Manager) registerChan(name interface{}, c Chan) {
 	m.chans[name] = append(m.chans[name], c)
 }
 
-// broadcastNewConfig sends a configuration pointer in all registered channels.
-func (m *Manager) broadcastNewConfig(name interface{}) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// broadcastNewConfig sends a configuration pointer in all registered channels
+// and the parts of the configuration which have changed to the subscriptions.
+// The lock is not held while sending so that receivers can use the Manager.
+// Channels are only closed once the reload executor has returned so they can
+// not be closed while sending.
+func (m *Manager) broadcastNewConfig(name interface{}, previousConfig Config, info *ReloadInfo) {
+	m.mu.RLock()
+	w, ok := m.watchers[name]
+	chans := m.chans[name]
+	reloadChans := m.reloadChans[name]
+	subscriptions := m.subscriptions[name]
+	m.mu.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	config := w.config
 
-	for i := range m.chans[name] {
-		m.logger.Tracef("Signaling new conf %p in chan %p", m.watchers[name].config, m.chans[name][i])
-		m.chans[name][i] <- m.watchers[name].config
+	// Sends are given up once the watcher is stopped so that deleting the
+	// configuration does not wait for channels nobody reads
+	for i := range chans {
+		m.logger.Tracef("Signaling new conf %p in chan %p", config, chans[i])
+
+		select {
+		case chans[i] <- config:
+		case <-w.stopped:
+			return
+		}
+	}
+
+	for _, c := range reloadChans {
+		m.logger.Tracef("Signaling reload event in chan %p", c)
+
+		select {
+		case c <- &ReloadEvent{ReloadInfo: info, Config: config}:
+		case <-w.stopped:
+			return
+		}
+	}
+
+	for _, s := range subscriptions {
+		value, changed, err := s.selection(previousConfig, config, info.Changes)
+
+		if err != nil {
+			m.logger.Errorf("Error while selecting subscription value: %v", err)
+			continue
+		}
+
+		if changed {
+			m.logger.Tracef("Signaling new value in chan %p", s.c)
+
+			select {
+			case s.c <- value:
+			case <-w.stopped:
+				return
+			}
+		}
 	}
 }
 
@@ -187,13 +441,59 @@ func (m *Manager) AddValidators(name interface{}, validators ...Validator) {
 	}
 }
 
+// AddInfoValidators registers validators which receive details about the
+// reload, e.g. its reason.
+func (m *Manager) AddInfoValidators(name interface{}, validators ...InfoValidator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, validator := range validators {
+		validator := validator
+		m.registerValidator(name, func(_ context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) []error {
+			return validator(currentConfig, newConfig, info)
+		})
+	}
+}
+
+// AddValidatorsCtx registers validators which receive a context, e.g. to make
+// cancellable network checks, and details about the reload.
+func (m *Manager) AddValidatorsCtx(name interface{}, validators ...ValidatorCtx) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, validator := range validators {
+		m.registerValidator(name, validator)
+	}
+}
+
 // addValidator ...
 func (m *Manager) addValidator(name interface{}, validator Validator) {
+	m.registerValidator(name, func(_ context.Context, currentConfig Config, newConfig Config, _ *ReloadInfo) []error {
+		return validator(currentConfig, newConfig)
+	})
+}
+
+// AddNamedValidator registers a validator whose errors are reported under the
+// given name, see ValidationReport.
+func (m *Manager) AddNamedValidator(name interface{}, validatorName string, validator ValidatorCtx) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.registerValidator(name, validator)
+
+	validators := m.validators[name]
+	validators[len(validators)-1].name = validatorName
+}
+
+func (m *Manager) registerValidator(name interface{}, validator ValidatorCtx) {
 	if m.validators == nil {
-		m.validators = make(map[interface{}][]Validator)
+		m.validators = make(map[interface{}][]registeredValidator)
 	}
 
-	m.validators[name] = append(m.validators[name], validator)
+	m.validators[name] = append(m.validators[name], registeredValidator{
+		name:     fmt.Sprintf("#%d", len(m.validators[name])+1),
+		validate: validator,
+	})
 }
 
 // AddAppliers ...
@@ -206,36 +506,99 @@ func (m *Manager) AddAppliers(name interface{}, appliers ...Applier) {
 	}
 }
 
+// AddInfoApplier registers an applier which receives the changes made to the
+// configuration. If prefixes are given, the applier is skipped when none of
+// the changes affect one of them, e.g. an applier registered with the
+// `database` prefix is only run when something under `database` has changed.
+func (m *Manager) AddInfoApplier(name interface{}, applier InfoApplier, prefixes ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_ = m.registerApplier(name, registeredApplier{
+		prepare: func(_ context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+			return applier(currentConfig, newConfig, info)
+		},
+		prefixes: prefixes,
+	})
+}
+
+// AddApplierCtx registers an applier which receives a context and details
+// about the reload. Prefixes work the same way as with AddInfoApplier.
+func (m *Manager) AddApplierCtx(name interface{}, applier ApplierCtx, prefixes ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_ = m.registerApplier(name, registeredApplier{prepare: applier, prefixes: prefixes})
+}
+
 // addApplier ...
 func (m *Manager) addApplier(name interface{}, applier Applier) {
-	if m.appliers == nil {
-		m.appliers = make(map[interface{}][]Applier)
-	}
+	_ = m.registerApplier(name, registeredApplier{
+		prepare: func(_ context.Context, currentConfig Config, newConfig Config, _ *ReloadInfo) error {
+			return applier(currentConfig, newConfig)
+		},
+	})
+}
 
-	m.appliers[name] = append(m.appliers[name], applier)
+// registeredValidator is a validator and the name its errors are reported
+// under.
+type registeredValidator struct {
+	name     string
+	validate ValidatorCtx
 }
 
-func (m *Manager) runValidators(name interface{}, currentConfig Config, newConfig Config) []error {
-	var errs []error
-	for _, validator := range m.validators[name] {
-		verrs := validator(currentConfig, newConfig)
+// runValidators checks the `validate` struct tags of newConfig and then runs
+// the validators registered for the named configuration, at most concurrency
+// at a time, or GOMAXPROCS at a time if concurrency is not set. Results are returned in registration order, preceded by the
+// errors of the struct tags and of the Validate methods. Panics are returned
+// as errors.
+func (m *Manager) runValidators(ctx context.Context, name interface{}, concurrency int, currentConfig Config, newConfig Config, info *ReloadInfo) []ValidatorReport {
+	m.mu.RLock()
+	validators := m.validators[name]
+	m.mu.RUnlock()
+
+	results := make([]ValidatorReport, len(validators)+2)
+	results[0] = ValidatorReport{Name: ReportTags, Errors: validateTags(newConfig)}
+	results[1] = ValidatorReport{Name: ReportMethods, Errors: validateMethods(currentConfig, newConfig)}
+
+	if concurrency < 1 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
 
-		if verrs != nil {
-			errs = append(errs, verrs...)
+	for i, validator := range validators {
+		if ctx.Err() != nil {
+			break
 		}
+
+		results[i+2].Name = validator.name
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(i int, validator registeredValidator) {
+			defer func() { <-sem; wg.Done() }()
+
+			results[i+2].Errors = callValidator(ctx, validator.validate, currentConfig, newConfig, info)
+		}(i, validator)
 	}
 
-	return errs
+	wg.Wait()
+
+	return results
 }
 
-func (m *Manager) runAppliers(name interface{}, currentConfig Config, newConfig Config) error {
-	for _, applier := range m.appliers[name] {
-		err := applier(currentConfig, newConfig)
+// callValidator runs validator, converting its panic, if any, into an error.
+func callValidator(ctx context.Context, validator ValidatorCtx, currentConfig Config, newConfig Config, info *ReloadInfo) (errs []error) {
+	var err error
 
+	defer func() {
 		if err != nil {
-			return err
+			errs = append(errs, err)
 		}
-	}
+	}()
+	defer recoverPanic(&err)
 
-	return nil
+	return validator(ctx, currentConfig, newConfig, info)
 }
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"reflect"
+)
+
+// DeepCopier is an optional interface of Config. Configs which implement it,
+// e.g. with qdconfig-gen, are copied with DeepCopyConfig, which is faster than
+// the reflection based copy used otherwise.
+type DeepCopier interface {
+	// DeepCopyConfig returns a copy of the current struct.
+	DeepCopyConfig() Config
+}
+
+// copyConfig returns a deep copy of conf.
+func copyConfig(conf Config) Config {
+	if conf == nil {
+		return nil
+	}
+
+	if c, ok := conf.(DeepCopier); ok {
+		return c.DeepCopyConfig()
+	}
+
+	out, _ := deepCopy(conf).(Config)
+
+	return out
+}
+
+// deepCopy returns a deep copy of src, which can be anything. Pointers, maps,
+// slices and interfaces are copied recursively, pointers and maps referenced
+// several times, including by themselves, are copied once. Unexported struct
+// fields, channels and functions are copied shallowly.
+func deepCopy(src interface{}) interface{} {
+	if src == nil {
+		return nil
+	}
+
+	v := reflect.ValueOf(src)
+	dst := reflect.New(v.Type()).Elem()
+	copyValue(dst, v, make(map[copyKey]reflect.Value))
+
+	return dst.Interface()
+}
+
+// copyKey identifies a pointer or a map which has already been copied.
+type copyKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// copyValue deep copies src into dst, which must be settable.
+func copyValue(dst reflect.Value, src reflect.Value, copied map[copyKey]reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+
+		key := copyKey{src.Pointer(), src.Type()}
+
+		if c, ok := copied[key]; ok {
+			dst.Set(c)
+			return
+		}
+
+		p := reflect.New(src.Type().Elem())
+		copied[key] = p
+		copyValue(p.Elem(), src.Elem(), copied)
+		dst.Set(p)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+
+		e := reflect.New(src.Elem().Type()).Elem()
+		copyValue(e, src.Elem(), copied)
+		dst.Set(e)
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+
+		key := copyKey{src.Pointer(), src.Type()}
+
+		if c, ok := copied[key]; ok {
+			dst.Set(c)
+			return
+		}
+
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		copied[key] = m
+
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			copyValue(k, iter.Key(), copied)
+
+			e := reflect.New(src.Type().Elem()).Elem()
+			copyValue(e, iter.Value(), copied)
+
+			m.SetMapIndex(k, e)
+		}
+
+		dst.Set(m)
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+
+		for i := 0; i < src.Len(); i++ {
+			copyValue(s.Index(i), src.Index(i), copied)
+		}
+
+		dst.Set(s)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			copyValue(dst.Index(i), src.Index(i), copied)
+		}
+	case reflect.Struct:
+		// Unexported fields can only be copied shallowly
+		dst.Set(src)
+
+		for i := 0; i < src.NumField(); i++ {
+			if len(src.Type().Field(i).PkgPath) > 0 {
+				continue
+			}
+
+			copyValue(dst.Field(i), src.Field(i), copied)
+		}
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"context"
+	"testing"
+)
+
+type copyNode struct {
+	Name string
+	Next *copyNode
+}
+
+type copyConfigType struct {
+	File     string `short:"f" long:"config"`
+	Verbose  []bool `short:"v" long:"verbose"`
+	Labels   map[string][]string
+	Extra    interface{}
+	Head     *copyNode `no-flag:"t"`
+	Ports    [2]int
+	internal *copyNode
+}
+
+func (c *copyConfigType) ConfigFile() string {
+	return c.File
+}
+
+func TestDeepCopy(t *testing.T) {
+	head := &copyNode{Name: "a"}
+	head.Next = &copyNode{Name: "b", Next: head}
+
+	src := &copyConfigType{
+		Labels:   map[string][]string{"env": {"prod"}},
+		Extra:    map[string]interface{}{"key": []int{1}},
+		Head:     head,
+		Ports:    [2]int{80, 443},
+		internal: head,
+	}
+
+	dst := copyConfig(src).(*copyConfigType)
+
+	if dst == src || dst.Head == src.Head {
+		t.Fatal("pointers should have been copied")
+	}
+
+	// Cycles are preserved
+	if dst.Head.Next.Next != dst.Head || dst.Head.Next.Name != "b" {
+		t.Errorf("cycle not preserved")
+	}
+
+	dst.Labels["env"][0] = "dev"
+	dst.Extra.(map[string]interface{})["key"].([]int)[0] = 2
+	dst.Ports[0] = 8080
+
+	if src.Labels["env"][0] != "prod" || src.Extra.(map[string]interface{})["key"].([]int)[0] != 1 || src.Ports[0] != 80 {
+		t.Errorf("source has been modified: %+v", src)
+	}
+
+	// Unexported fields are copied shallowly
+	if dst.internal != head {
+		t.Errorf("unexported fields should be copied shallowly")
+	}
+}
+
+func TestMakeConfigWithoutDeepCopier(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-vv")
+
+	name := "reflect-copy"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	conf := &copyConfigType{Labels: map[string][]string{"env": {"prod"}}}
+
+	if err := confManager.MakeConfig(ctx, name, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	setArgs(t, "-vvv")
+	if err := confManager.Reload(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := confManager.GetConfig(name).(*copyConfigType)
+
+	if reloaded == conf || len(reloaded.Verbose) != 3 || len(conf.Verbose) != 2 {
+		t.Errorf("expected a new copy of the config, got %+v", reloaded)
+	}
+}
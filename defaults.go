@@ -0,0 +1,180 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaulter is an optional interface of Config. SetDefaults is called on every
+// load, after the defaults declared in `default` struct tags have been set and
+// before the configuration is loaded from the command line and the config
+// file, so it should only set fields which are still empty. Use a Mutator to
+// set defaults depending on the loaded values.
+type Defaulter interface {
+	SetDefaults()
+}
+
+// applyDefaults sets the fields of conf which are empty to the value of their
+// `default` struct tag, e.g. `default:"8080"`, and then calls SetDefaults if
+// conf is a Defaulter. Slices are given as comma separated values and
+// durations as understood by time.ParseDuration. Configs implementing
+// DefaultsApplier set their defaults themselves.
+//
+// Command line options, i.e. fields with a `short`, `long` or `ini-name` tag,
+// are skipped as go-flags sets their `default` tag itself. Defaults are set
+// before loading, so structs which only exist once loaded, such as slice
+// elements or structs pointed by pointers which were nil, are not defaulted.
+func applyDefaults(conf Config) error {
+	if conf == nil {
+		return nil
+	}
+
+	if a, ok := conf.(DefaultsApplier); ok {
+		if err := a.ApplyDefaults(); err != nil {
+			return err
+		}
+	} else if err := defaultValue(reflect.ValueOf(conf), ""); err != nil {
+		return err
+	}
+
+	if d, ok := conf.(Defaulter); ok {
+		d.SetDefaults()
+	}
+
+	return nil
+}
+
+// defaultValue walks v and sets the defaults of the struct fields it
+// encounters. Nil pointers to structs are left untouched.
+func defaultValue(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return defaultValue(v.Elem(), path)
+		}
+	case reflect.Struct:
+		t := v.Type()
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			// Skip unexported fields
+			if len(field.PkgPath) > 0 {
+				continue
+			}
+
+			fpath := path
+			if !isInlined(field) {
+				fpath = joinPath(path, fieldName(field))
+			}
+
+			fv := v.Field(i)
+
+			if tag, ok := field.Tag.Lookup("default"); ok && !isFlag(field) && fv.IsZero() {
+				if err := setDefault(fv, tag); err != nil {
+					return fmt.Errorf("invalid default value of `%s`: %w", fpath, err)
+				}
+			}
+
+			if err := defaultValue(fv, fpath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// isFlag returns true if go-flags considers field as a command line option.
+func isFlag(field reflect.StructField) bool {
+	for _, key := range []string{"short", "long", "ini-name"} {
+		if len(field.Tag.Get(key)) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setDefault parses s into v.
+func setDefault(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		return setDefault(v.Elem(), s)
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, v.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, v.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(f)
+	case reflect.Slice:
+		var parts []string
+		if len(s) > 0 {
+			parts = strings.Split(s, ",")
+		}
+
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+
+		for i, part := range parts {
+			if err := setDefault(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type defaultsServer struct {
+	Host    string        `yaml:"host"    default:"localhost"`
+	Port    int           `yaml:"port"    default:"8080"`
+	Timeout time.Duration `yaml:"timeout" default:"5s"`
+}
+
+type defaultsConfig struct {
+	File    string         `short:"f" long:"config" default:"config.yaml"`
+	Server  defaultsServer `yaml:"server"`
+	Tags    []string       `yaml:"tags"    default:"a, b"`
+	Ratio   *float64       `yaml:"ratio"   default:"0.5"`
+	Enabled bool           `yaml:"enabled" default:"true"`
+	Name    string         `yaml:"name"`
+}
+
+func (c *defaultsConfig) ConfigFile() string {
+	return c.File
+}
+
+func (c *defaultsConfig) DeepCopyConfig() Config {
+	out := *c
+	out.Tags = append([]string(nil), c.Tags...)
+
+	if c.Ratio != nil {
+		ratio := *c.Ratio
+		out.Ratio = &ratio
+	}
+
+	return &out
+}
+
+func (c *defaultsConfig) SetDefaults() {
+	if len(c.Name) == 0 {
+		c.Name = c.Server.Host
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	conf := &defaultsConfig{Server: defaultsServer{Port: 9090}}
+
+	if err := applyDefaults(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Server.Host != "localhost" || conf.Server.Timeout != 5*time.Second {
+		t.Errorf("unexpected server defaults %+v", conf.Server)
+	}
+
+	if conf.Server.Port != 9090 {
+		t.Errorf("non empty fields should not be defaulted, got port %d", conf.Server.Port)
+	}
+
+	if len(conf.Tags) != 2 || conf.Tags[0] != "a" || conf.Tags[1] != "b" {
+		t.Errorf("unexpected tags %v", conf.Tags)
+	}
+
+	if conf.Ratio == nil || *conf.Ratio != 0.5 || !conf.Enabled {
+		t.Errorf("unexpected defaults %+v", conf)
+	}
+
+	if len(conf.File) > 0 {
+		t.Errorf("defaults of command line options should be left to go-flags, got file %q", conf.File)
+	}
+
+	if conf.Name != "localhost" {
+		t.Errorf("expected SetDefaults to be called after tag defaults, got name %q", conf.Name)
+	}
+
+	type invalid struct {
+		Port int `default:"http"`
+	}
+
+	if err := defaultValue(reflect.ValueOf(&invalid{}), ""); err == nil {
+		t.Errorf("expected an error on invalid default")
+	}
+}
+
+func TestDefaultsOnReload(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	mustWrite(t, configFile, "server:\n  port: 1234\n")
+	setArgs(t, "-f", configFile)
+
+	name := "defaults"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	if err := confManager.MakeConfig(ctx, name, &defaultsConfig{}, WithPolling()); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf := confManager.GetConfig(name).(*defaultsConfig); conf.Server.Port != 1234 || conf.Server.Host != "localhost" {
+		t.Errorf("unexpected config %+v", conf)
+	}
+
+	// Defaults are applied again when a key is removed
+	mustWrite(t, configFile, "name: test\n")
+
+	if err := confManager.Reload(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf := confManager.GetConfig(name).(*defaultsConfig); conf.Server.Port != 8080 || conf.Name != "test" {
+		t.Errorf("unexpected config %+v", conf)
+	}
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ChangeType describes how a field has changed between two configurations.
+type ChangeType int
+
+const (
+	// ChangeModified means that the field exists in both configurations but
+	// has different values.
+	ChangeModified ChangeType = iota
+	// ChangeAdded means that the field only exists in the new configuration.
+	ChangeAdded
+	// ChangeRemoved means that the field only exists in the old configuration.
+	ChangeRemoved
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeModified:
+		return "modified"
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	}
+
+	return fmt.Sprintf("ChangeType(%d)", int(t))
+}
+
+// Change describes the change of a single field between two configurations.
+type Change struct {
+	// Path is the path of the field, e.g. `database.replicas[2].port`. The
+	// root of the configuration has an empty path.
+	Path string
+	// Type tells if the field has been modified, added or removed.
+	Type ChangeType
+	// Old is the value of the field in the current configuration, nil if the
+	// field has been added.
+	Old interface{}
+	// New is the value of the field in the new configuration, nil if the
+	// field has been removed.
+	New interface{}
+}
+
+func (c Change) String() string {
+	path := c.Path
+	if len(path) == 0 {
+		path = "<root>"
+	}
+
+	return fmt.Sprintf("%s (%s)", path, c.Type)
+}
+
+// Changes is the list of changes between two configurations.
+type Changes []Change
+
+// Paths returns the paths of all the changes.
+func (c Changes) Paths() []string {
+	paths := make([]string, 0, len(c))
+
+	for _, change := range c {
+		paths = append(paths, change.Path)
+	}
+
+	return paths
+}
+
+// Under returns the changes affecting the given path prefix, i.e. the changes
+// of the field designated by prefix, of its children and of its parents.
+func (c Changes) Under(prefix string) Changes {
+	var changes Changes
+
+	for _, change := range c {
+		if pathAffects(change.Path, prefix) {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+// Has returns true if any change affects the given path prefix.
+func (c Changes) Has(prefix string) bool {
+	for _, change := range c {
+		if pathAffects(change.Path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pathAffects returns true if a change on path affects prefix.
+func pathAffects(path string, prefix string) bool {
+	return isUnderPath(path, prefix) || isUnderPath(prefix, path)
+}
+
+// isUnderPath returns true if path designates the same field as parent or one
+// of its children.
+func isUnderPath(path string, parent string) bool {
+	if len(parent) == 0 || path == parent {
+		return true
+	}
+
+	if !strings.HasPrefix(path, parent) {
+		return false
+	}
+
+	next := path[len(parent)]
+
+	return next == '.' || next == '['
+}
+
+// diffConfigs computes the changes between currentConfig and newConfig. If
+// currentConfig is nil the whole configuration is reported as added. Configs
+// implementing Differ compute their changes themselves.
+func diffConfigs(currentConfig Config, newConfig Config) Changes {
+	if currentConfig == nil {
+		return Changes{{Path: "", Type: ChangeAdded, New: newConfig}}
+	}
+
+	if d, ok := newConfig.(Differ); ok && reflect.TypeOf(currentConfig) == reflect.TypeOf(newConfig) {
+		return d.DiffConfig(currentConfig)
+	}
+
+	return diffValues(reflect.ValueOf(currentConfig), reflect.ValueOf(newConfig), "", make(map[diffVisit]bool))
+}
+
+// diffVisit identifies a pair of pointers which have already been compared.
+type diffVisit struct {
+	old uintptr
+	new uintptr
+	typ reflect.Type
+}
+
+// diffValues computes the changes between oldV and newV. Pairs of pointers
+// already in visited are not compared again so that cycles terminate.
+func diffValues(oldV reflect.Value, newV reflect.Value, path string, visited map[diffVisit]bool) Changes {
+	if !oldV.IsValid() || !newV.IsValid() || oldV.Type() != newV.Type() {
+		return diffLeaf(oldV, newV, path)
+	}
+
+	var changes Changes
+
+	switch oldV.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if oldV.IsNil() || newV.IsNil() {
+			return diffLeaf(oldV, newV, path)
+		}
+
+		if oldV.Kind() == reflect.Ptr {
+			visit := diffVisit{oldV.Pointer(), newV.Pointer(), oldV.Type()}
+
+			if visited[visit] {
+				return nil
+			}
+
+			visited[visit] = true
+		}
+
+		changes = diffValues(oldV.Elem(), newV.Elem(), path, visited)
+	case reflect.Struct:
+		t := oldV.Type()
+
+		if !hasExportedFields(t) {
+			return diffLeaf(oldV, newV, path)
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			if len(field.PkgPath) > 0 {
+				continue
+			}
+
+			fpath := path
+			if !isInlined(field) {
+				fpath = joinPath(path, fieldName(field))
+			}
+
+			changes = append(changes, diffValues(oldV.Field(i), newV.Field(i), fpath, visited)...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < oldV.Len() || i < newV.Len(); i++ {
+			switch {
+			case i >= newV.Len():
+				changes = append(changes, Change{Path: indexPath(path, i), Type: ChangeRemoved, Old: oldV.Index(i).Interface()})
+			case i >= oldV.Len():
+				changes = append(changes, Change{Path: indexPath(path, i), Type: ChangeAdded, New: newV.Index(i).Interface()})
+			default:
+				changes = append(changes, diffValues(oldV.Index(i), newV.Index(i), indexPath(path, i), visited)...)
+			}
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(oldV) {
+			nv := newV.MapIndex(key)
+
+			if !nv.IsValid() {
+				changes = append(changes, Change{Path: keyPath(path, key), Type: ChangeRemoved, Old: oldV.MapIndex(key).Interface()})
+				continue
+			}
+
+			changes = append(changes, diffValues(oldV.MapIndex(key), nv, keyPath(path, key), visited)...)
+		}
+
+		for _, key := range sortedMapKeys(newV) {
+			if !oldV.MapIndex(key).IsValid() {
+				changes = append(changes, Change{Path: keyPath(path, key), Type: ChangeAdded, New: newV.MapIndex(key).Interface()})
+			}
+		}
+	default:
+		return diffLeaf(oldV, newV, path)
+	}
+
+	return changes
+}
+
+// diffLeaf compares two values as a whole.
+func diffLeaf(oldV reflect.Value, newV reflect.Value, path string) Changes {
+	oldNil := isNilValue(oldV)
+	newNil := isNilValue(newV)
+
+	switch {
+	case oldNil && newNil:
+		return nil
+	case oldNil:
+		return Changes{{Path: path, Type: ChangeAdded, New: newV.Interface()}}
+	case newNil:
+		return Changes{{Path: path, Type: ChangeRemoved, Old: oldV.Interface()}}
+	}
+
+	if reflect.DeepEqual(oldV.Interface(), newV.Interface()) {
+		return nil
+	}
+
+	return Changes{{Path: path, Type: ChangeModified, Old: oldV.Interface(), New: newV.Interface()}}
+}
+
+// isNilValue returns true for invalid values and nil pointers, interfaces,
+// maps and slices.
+func isNilValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// hasExportedFields returns true if the struct type t has exported fields.
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if len(t.Field(i).PkgPath) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+type diffDatabase struct {
+	Host     string   `yaml:"host"`
+	Replicas []string `yaml:"replicas"`
+}
+
+type diffConfig struct {
+	File     string            `yaml:"file"`
+	Database *diffDatabase     `yaml:"database"`
+	Labels   map[string]string `yaml:"labels"`
+}
+
+func (c *diffConfig) DeepCopyConfig() Config {
+	copy := *c
+	return &copy
+}
+
+func (c *diffConfig) ConfigFile() string {
+	return c.File
+}
+
+func TestDiffConfigs(t *testing.T) {
+	current := &diffConfig{
+		File:     "config.yaml",
+		Database: &diffDatabase{Host: "db1", Replicas: []string{"r1", "r2"}},
+		Labels:   map[string]string{"env": "prod", "team": "a"},
+	}
+	newConf := &diffConfig{
+		File:     "config.yaml",
+		Database: &diffDatabase{Host: "db2", Replicas: []string{"r1"}},
+		Labels:   map[string]string{"env": "prod", "zone": "b"},
+	}
+
+	changes := diffConfigs(current, newConf)
+
+	expected := Changes{
+		{Path: "database.host", Type: ChangeModified, Old: "db1", New: "db2"},
+		{Path: "database.replicas[1]", Type: ChangeRemoved, Old: "r2"},
+		{Path: "labels[team]", Type: ChangeRemoved, Old: "a"},
+		{Path: "labels[zone]", Type: ChangeAdded, New: "b"},
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("unexpected changes:\n%#v\nexpected:\n%#v", changes, expected)
+	}
+
+	for prefix, has := range map[string]bool{
+		"database":          true,
+		"database.host":     true,
+		"database.replicas": true,
+		"databases":         false,
+		"file":              false,
+		"labels[env]":       false,
+		"labels":            true,
+		"":                  true,
+	} {
+		if changes.Has(prefix) != has {
+			t.Errorf("Has(%q) should be %v", prefix, has)
+		}
+	}
+
+	if under := changes.Under("database"); len(under) != 2 {
+		t.Errorf("expected 2 changes under database, got %v", under)
+	}
+
+	if changes := diffConfigs(current, current); len(changes) > 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+
+	// Initial load reports the whole config as added
+	if changes := diffConfigs(nil, newConf); !changes.Has("database.host") {
+		t.Errorf("initial changes should affect every path, got %v", changes)
+	}
+}
+
+type diffNode struct {
+	Name string    `yaml:"name"`
+	Next *diffNode `yaml:"next"`
+}
+
+type diffCycleConfig struct {
+	File string    `long:"config"`
+	Head *diffNode `yaml:"head"`
+}
+
+func (c *diffCycleConfig) ConfigFile() string {
+	return c.File
+}
+
+func TestDiffConfigsCycle(t *testing.T) {
+	cycle := func(name string) *diffCycleConfig {
+		a := &diffNode{Name: "a"}
+		a.Next = &diffNode{Name: name, Next: a}
+
+		return &diffCycleConfig{Head: a}
+	}
+
+	changes := diffConfigs(cycle("b"), cycle("c"))
+
+	if len(changes) != 1 || changes[0].Path != "head.next.name" {
+		t.Errorf("expected a change on head.next.name, got %v", changes)
+	}
+}
@@ -0,0 +1,97 @@
+// Code generated by qdconfig-gen. DO NOT EDIT.
+
+package config
+
+import (
+	qdconfig "sylr.dev/libqd/config"
+)
+
+// DeepCopyInto copies the receiver into out. in must be non-nil.
+func (in *MyAppConfiguration) DeepCopyInto(out *MyAppConfiguration) {
+	*out = *in
+	if in.Verbose != nil {
+		out.Verbose = make([]bool, len(in.Verbose))
+		copy(out.Verbose, in.Verbose)
+	}
+}
+
+// DeepCopy returns a deep copy of the receiver.
+func (in *MyAppConfiguration) DeepCopy() *MyAppConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(MyAppConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (c *MyAppConfiguration) qdconfigApplyDefaults(path string) error {
+	return nil
+}
+
+func (c *MyAppConfiguration) qdconfigValidateTags(path string) []error {
+	var errs []error
+	errs = append(errs, qdconfig.ValidateField(qdconfig.JoinPath(path, "port"), c.HTTPPort, "min=1,max=65535")...)
+	return errs
+}
+
+func (c *MyAppConfiguration) qdconfigDiff(old *MyAppConfiguration, path string) qdconfig.Changes {
+	var changes qdconfig.Changes
+	if old.Reloads != c.Reloads {
+		changes = append(changes, qdconfig.Change{Path: qdconfig.JoinPath(path, "reloads"), Type: qdconfig.ChangeModified, Old: old.Reloads, New: c.Reloads})
+	}
+	if old.Version != c.Version {
+		changes = append(changes, qdconfig.Change{Path: qdconfig.JoinPath(path, "version"), Type: qdconfig.ChangeModified, Old: old.Version, New: c.Version})
+	}
+	if old.File != c.File {
+		changes = append(changes, qdconfig.Change{Path: qdconfig.JoinPath(path, "file"), Type: qdconfig.ChangeModified, Old: old.File, New: c.File})
+	}
+	changes = append(changes, qdconfig.DiffValues(qdconfig.JoinPath(path, "verbose"), old.Verbose, c.Verbose)...)
+	if old.HTTPPort != c.HTTPPort {
+		changes = append(changes, qdconfig.Change{Path: qdconfig.JoinPath(path, "port"), Type: qdconfig.ChangeModified, Old: old.HTTPPort, New: c.HTTPPort})
+	}
+	return changes
+}
+
+// DeepCopyConfig returns a deep copy of the receiver.
+func (in *MyAppConfiguration) DeepCopyConfig() qdconfig.Config {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// ConfigFile returns the path of the configuration file.
+func (c *MyAppConfiguration) ConfigFile() string {
+	return c.File
+}
+
+// ApplyDefaults sets the empty fields to the value of their `default` struct
+// tag.
+func (c *MyAppConfiguration) ApplyDefaults() error {
+	return c.qdconfigApplyDefaults("")
+}
+
+// ValidateTags checks the fields against the rules of their `validate` struct
+// tag.
+func (c *MyAppConfiguration) ValidateTags() []error {
+	return c.qdconfigValidateTags("")
+}
+
+// DiffConfig returns the changes from old to the receiver.
+func (c *MyAppConfiguration) DiffConfig(old qdconfig.Config) qdconfig.Changes {
+	o, ok := old.(*MyAppConfiguration)
+	if !ok || o == nil || c == nil {
+		return qdconfig.DiffValues("", old, c)
+	}
+	return c.qdconfigDiff(o, "")
+}
+
+// MyAppConfigurationEnvVars maps the names of environment variables to the paths of
+// the fields of MyAppConfiguration they set.
+var MyAppConfigurationEnvVars = map[string]string{
+	"MYAPP_FILE":    "file",
+	"MYAPP_PORT":    "port",
+	"MYAPP_VERBOSE": "verbose",
+	"MYAPP_VERSION": "version",
+}
@@ -51,10 +51,7 @@ func main() {
 	logger := logrus.StandardLogger()
 	qdlogger := &Logger{l: logger}
 
-	conf := &config.MyAppConfiguration{
-		HTTPPort: 8080,
-		File:     "./config.yaml",
-	}
+	conf := &config.MyAppConfiguration{}
 
 	ctx := context.Background()
 
@@ -63,7 +60,10 @@ func main() {
 	cm := configMutex{&mu, qdlogger}
 
 	// Manager
-	configManager := qdconfig.GetManager(qdlogger)
+	configManager := qdconfig.NewManager(qdconfig.WithLogger(qdlogger))
+
+	// Add a mutator function
+	configManager.AddMutators(nil, cm.configMutator)
 
 	// Add a validator function
 	configManager.AddValidators(nil, cm.configValidator)
@@ -115,59 +115,43 @@ type configMutex struct {
 	l *Logger
 }
 
-func (cm *configMutex) configValidator(currentConfig qdconfig.Config, newConfig qdconfig.Config) []error {
-	var currentConf *config.MyAppConfiguration
-	var newConf *config.MyAppConfiguration
-	var ok bool
+func (cm *configMutex) configMutator(currentConfig qdconfig.Config, newConfig qdconfig.Config) error {
+	// currentConfig is nil the first time the mutator is called
+	if currentConfig == nil {
+		return nil
+	}
 
-	// currentConfig is nil the first time the validator is called
-	if currentConfig != nil {
-		currentConf, ok = currentConfig.(*config.MyAppConfiguration)
+	currentConf, ok := currentConfig.(*config.MyAppConfiguration)
 
-		if !ok {
-			return []error{fmt.Errorf("Can not cast currentConfig to (*config.MyAppConfiguration)")}
-		}
+	if !ok {
+		return fmt.Errorf("Can not cast currentConfig to (*config.MyAppConfiguration)")
 	}
 
-	newConf, ok = newConfig.(*config.MyAppConfiguration)
+	newConf, ok := newConfig.(*config.MyAppConfiguration)
 
 	if !ok {
-		return []error{fmt.Errorf("Can not cast newConfig to (*config.MyAppConfiguration)")}
+		return fmt.Errorf("Can not cast newConfig to (*config.MyAppConfiguration)")
 	}
 
-	// ---------------------------------------------------------------------
-	// Here begins the actual validation of the values of newConfig
-	// ---------------------------------------------------------------------
-	var errs []error
+	newConf.Reloads = currentConf.Reloads + 1
+	cm.l.Debugf("Incrementing conf.Reloads to `%d`", newConf.Reloads)
 
-	if currentConfig == nil {
-		if newConf.HTTPPort < 0 || newConf.HTTPPort > 65535 {
-			errs = append(errs, fmt.Errorf("HTTPPort `%d` is not valid", newConf.HTTPPort))
-		}
-	} else {
-		if newConf.HTTPPort != currentConf.HTTPPort {
-			errs = append(errs, fmt.Errorf("HTTPPort `%d` can not be changed to `%d`", currentConf.HTTPPort, newConf.HTTPPort))
-		}
+	return nil
+}
+
+func (cm *configMutex) configValidator(currentConfig qdconfig.Config, newConfig qdconfig.Config) []error {
+	if _, ok := newConfig.(*config.MyAppConfiguration); !ok {
+		return []error{fmt.Errorf("Can not cast newConfig to (*config.MyAppConfiguration)")}
 	}
 
-	return errs
+	// HTTPPort range is checked by its `validate` struct tag and it can not be
+	// changed at runtime because of its `reload:"restart"` struct tag. Checks
+	// which can not be expressed with struct tags go here.
+	return nil
 }
 
 func (cm *configMutex) configApplier(currentConfig qdconfig.Config, newConfig qdconfig.Config) error {
-	var currentConf *config.MyAppConfiguration
-	var newConf *config.MyAppConfiguration
-	var ok bool
-
-	// currentConfig is nil the first time the validator is called
-	if currentConfig != nil {
-		currentConf, ok = currentConfig.(*config.MyAppConfiguration)
-
-		if !ok {
-			return fmt.Errorf("Can not cast currentConfig to (*config.MyAppConfiguration)")
-		}
-	}
-
-	newConf, ok = newConfig.(*config.MyAppConfiguration)
+	newConf, ok := newConfig.(*config.MyAppConfiguration)
 
 	if !ok {
 		return fmt.Errorf("Can not cast newConfig to (*config.MyAppConfiguration)")
@@ -190,12 +174,5 @@ func (cm *configMutex) configApplier(currentConfig qdconfig.Config, newConfig qd
 		logrus.SetLevel(logrus.TraceLevel)
 	}
 
-	if currentConf != nil {
-		cm.Lock()
-		newConf.Reloads = newConf.Reloads + 1
-		cm.l.Debugf("Incrementing conf.Reloads to `%d`", newConf.Reloads)
-		cm.Unlock()
-	}
-
 	return nil
 }
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"encoding/json"
+
+	toml "github.com/BurntSushi/toml"
+	"github.com/tailscale/hujson"
+	yaml "sylr.dev/yaml/v3"
+)
+
+// Parser is a function type which decodes the content of a config file into
+// a Config.
+type Parser func(conf Config, content []byte) error
+
+// builtinParsers returns the parsers of the supported formats indexed by file
+// extension.
+func builtinParsers() map[string]Parser {
+	return map[string]Parser{
+		".yaml": parseYAML,
+		".yml":  parseYAML,
+		".json": parseJSON,
+		".toml": parseTOML,
+	}
+}
+
+// parseYAML parses the YAML input into a Config.
+func parseYAML(conf Config, bytes []byte) error {
+	err := yaml.Unmarshal([]byte(bytes), conf)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseJSON parses the JSON input into a Config.
+func parseJSON(conf Config, bytes []byte) error {
+	ast, err := hujson.Parse(bytes)
+
+	if err != nil {
+		return err
+	}
+
+	ast.Standardize()
+	data := ast.Pack()
+
+	return json.Unmarshal(data, conf)
+}
+
+// parseTOML parses the TOML input into a Config.
+func parseTOML(conf Config, bytes []byte) error {
+	err := toml.Unmarshal([]byte(bytes), conf)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// FS is the interface that describes the filesystem from which config files
+// are read. Filesystem notifications are always received from the operating
+// system, so alternative implementations should be used along with polling.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Readlink(name string) (string, error)
+}
+
+// osFS is the FS backed by the os package.
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (osFS) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
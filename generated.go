@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// The interfaces and functions of this file are implemented and used by the
+// code generated by qdconfig-gen (see cmd/qdconfig-gen), which replaces the
+// reflection based walks of the configuration with code specific to its type.
+
+// DefaultsApplier is an optional interface of Config. If implemented,
+// ApplyDefaults is called instead of walking the configuration by reflection
+// to set the defaults declared in `default` struct tags.
+type DefaultsApplier interface {
+	ApplyDefaults() error
+}
+
+// TagValidator is an optional interface of Config. If implemented,
+// ValidateTags is called instead of walking the configuration by reflection to
+// check the rules declared in `validate` struct tags.
+type TagValidator interface {
+	ValidateTags() []error
+}
+
+// Differ is an optional interface of Config. If implemented, DiffConfig is
+// called on the new configuration to compute its changes from the current
+// one instead of comparing them by reflection.
+type Differ interface {
+	DiffConfig(old Config) Changes
+}
+
+// JoinPath appends a field name to a field path.
+func JoinPath(parent string, name string) string {
+	return joinPath(parent, name)
+}
+
+// IndexPath appends a slice index to a field path.
+func IndexPath(parent string, i int) string {
+	return indexPath(parent, i)
+}
+
+// SetDefault sets the value pointed by ptr to s if it is empty. See
+// applyDefaults for the supported formats.
+func SetDefault(path string, ptr interface{}, s string) error {
+	v := reflect.ValueOf(ptr).Elem()
+
+	if !v.IsZero() {
+		return nil
+	}
+
+	if err := setDefault(v, s); err != nil {
+		return fmt.Errorf("invalid default value of `%s`: %w", path, err)
+	}
+
+	return nil
+}
+
+// DefaultValue walks the value pointed by ptr and sets the defaults of the
+// struct fields it encounters, like the reflection based ApplyDefaults does.
+func DefaultValue(path string, ptr interface{}) error {
+	return defaultValue(reflect.ValueOf(ptr), path)
+}
+
+// ValidateField checks value against the rules of a `validate` struct tag.
+func ValidateField(path string, value interface{}, tag string) []error {
+	return validateField(reflect.ValueOf(value), path, tag)
+}
+
+// ValidateValue walks value and checks all the struct fields it encounters.
+func ValidateValue(path string, value interface{}) []error {
+	return validateValue(reflect.ValueOf(value), path, make(map[validateVisit]bool))
+}
+
+// DiffValues computes the changes between two values of a field.
+func DiffValues(path string, oldValue interface{}, newValue interface{}) Changes {
+	return diffValues(reflect.ValueOf(oldValue), reflect.ValueOf(newValue), path, make(map[diffVisit]bool))
+}
+
+// DeepCopyValue returns a deep copy of v.
+func DeepCopyValue(v interface{}) interface{} {
+	return deepCopy(v)
+}
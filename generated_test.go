@@ -0,0 +1,105 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// generatedConfig implements the interfaces of the code generated by
+// qdconfig-gen so that they can be told apart from the reflection paths.
+type generatedConfig struct {
+	File    string `short:"f" long:"config"`
+	Verbose []bool `short:"v" long:"verbose"`
+	Port    int    `yaml:"port" default:"8080" validate:"min=100"`
+
+	defaults  *atomic.Int32
+	validates *atomic.Int32
+	diffs     *atomic.Int32
+}
+
+func (c *generatedConfig) ConfigFile() string {
+	return c.File
+}
+
+func (c *generatedConfig) DeepCopyConfig() Config {
+	out := *c
+	out.Verbose = append([]bool(nil), c.Verbose...)
+
+	return &out
+}
+
+func (c *generatedConfig) ApplyDefaults() error {
+	c.defaults.Inc()
+
+	if c.Port == 0 {
+		c.Port = 50
+	}
+
+	return nil
+}
+
+func (c *generatedConfig) ValidateTags() []error {
+	c.validates.Inc()
+	return nil
+}
+
+func (c *generatedConfig) DiffConfig(old Config) Changes {
+	c.diffs.Inc()
+	return Changes{{Path: "generated", Type: ChangeModified}}
+}
+
+func TestGeneratedInterfaces(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "generated"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	conf := &generatedConfig{
+		defaults:  atomic.NewInt32(0),
+		validates: atomic.NewInt32(0),
+		diffs:     atomic.NewInt32(0),
+	}
+
+	// The `default` and `validate` tags would set 8080 and reject 50
+	if err := confManager.MakeConfig(ctx, name, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if port := confManager.GetConfig(name).(*generatedConfig).Port; port != 50 {
+		t.Errorf("expected port 50 set by ApplyDefaults, got %d", port)
+	}
+
+	events := confManager.NewReloadChan(name)
+	errc := make(chan error, 1)
+
+	setArgs(t, "-vv")
+	go func() { errc <- confManager.Reload(ctx, name) }()
+
+	select {
+	case event := <-events:
+		if !event.Changes.Has("generated") || event.Changes.Has("verbose") {
+			t.Errorf("expected changes computed by DiffConfig, got %v", event.Changes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No reload event received")
+	}
+
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.defaults.Load() < 2 || conf.validates.Load() < 2 || conf.diffs.Load() < 1 {
+		t.Errorf("expected generated methods to be called, got %d ApplyDefaults, %d ValidateTags and %d DiffConfig",
+			conf.defaults.Load(), conf.validates.Load(), conf.diffs.Load())
+	}
+}
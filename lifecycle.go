@@ -0,0 +1,139 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrManagerClosed is returned by MakeConfig once the Manager has been shut down.
+var ErrManagerClosed = errors.New("config manager has been closed")
+
+// DeleteConfig removes the named configuration. It stops watching its config
+// file, waits for the reload in progress, if any, and closes all the channels
+// which have been returned for this configuration, including the ones of its
+// views. Mutators, validators and appliers registered under name are kept and
+// apply to the configuration if it is created again.
+func (m *Manager) DeleteConfig(name interface{}) error {
+	return m.deleteConfig(context.Background(), name)
+}
+
+// Close shuts down the Manager, see Shutdown. It waits for reloads in progress
+// without any deadline.
+func (m *Manager) Close() error {
+	return m.Shutdown(context.Background())
+}
+
+// Shutdown deletes all the configurations of the Manager, waiting for reloads
+// in progress and their appliers to complete, and prevents new configurations
+// from being created. Channels are not closed if ctx is done before reloads
+// in progress are complete, in which case ctx.Err() is returned.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.doneChan())
+	}
+
+	names := make([]interface{}, 0, len(m.watchers))
+	for name := range m.watchers {
+		names = append(names, name)
+	}
+	m.mu.Unlock()
+
+	var err error
+
+	for _, name := range names {
+		if derr := m.deleteConfig(ctx, name); derr != nil && err == nil {
+			err = derr
+		}
+	}
+
+	return err
+}
+
+// doneChan returns the channel closed when the Manager is shut down. It must
+// be called with the lock held.
+func (m *Manager) doneChan() chan struct{} {
+	if m.done == nil {
+		m.done = make(chan struct{})
+	}
+
+	return m.done
+}
+
+func (m *Manager) deleteConfig(ctx context.Context, name interface{}) error {
+	m.mu.Lock()
+
+	w, ok := m.watchers[name]
+
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("configuration `%v` does not exist", name)
+	}
+
+	chans := m.chans[name]
+	reloadChans := m.reloadChans[name]
+	subscriptions := m.subscriptions[name]
+	views := m.views[name]
+
+	delete(m.watchers, name)
+	delete(m.chans, name)
+	delete(m.reloadChans, name)
+	delete(m.subscriptions, name)
+	delete(m.views, name)
+	m.mu.Unlock()
+
+	m.logger.Debugf("Deleting configuration `%v`", name)
+
+	if err := w.stop(ctx); err != nil {
+		return err
+	}
+
+	// No reload can happen anymore so channels can safely be closed
+	for _, c := range chans {
+		close(c)
+	}
+
+	for _, c := range reloadChans {
+		close(c)
+	}
+
+	for _, s := range subscriptions {
+		close(s.c)
+	}
+
+	for _, v := range views {
+		v.closeChans()
+	}
+
+	return nil
+}
+
+// stop stops watching the config file and waits for the reload in progress, if
+// any. Once stopped, the watcher does not accept reloads anymore.
+func (w *watcher) stop(ctx context.Context) error {
+	w.stopOnce.Do(func() {
+		close(w.stopped)
+	})
+
+	if w.cancel != nil {
+		w.cancel()
+	}
+
+	// Wait for the reload executor to complete the reload in progress
+	select {
+	case <-w.executorDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// Wait for the watching go routine to release its resources
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeleteConfig(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	mustWrite(t, configFile, "verbose: [true]\n")
+	setArgs(t, "-f", configFile)
+
+	name := "delete"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validators outlive the deletion of the configuration
+	confManager.AddValidators(name, func(currentConfig Config, newConfig Config) []error {
+		if len(newConfig.(*MyConfig).Verbose) > 1 {
+			return []error{errors.New("too verbose")}
+		}
+
+		return nil
+	})
+
+	c := confManager.NewConfigChan(name)
+	events := confManager.NewReloadChan(name)
+	values, err := confManager.Subscribe(name, "verbose")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := confManager.DeleteConfig(name); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, closed := range []func() bool{
+		func() bool { _, ok := <-c; return !ok },
+		func() bool { _, ok := <-events; return !ok },
+		func() bool { _, ok := <-values; return !ok },
+	} {
+		if !closed() {
+			t.Errorf("channel should have been closed")
+		}
+	}
+
+	if confManager.GetWatcher(name) != nil || confManager.GetConfig(name) != nil {
+		t.Errorf("configuration should have been removed")
+	}
+
+	if err := confManager.Reload(ctx, name); err == nil {
+		t.Errorf("reloading a deleted configuration should fail")
+	}
+
+	if err := confManager.DeleteConfig(name); err == nil {
+		t.Errorf("deleting a deleted configuration should fail")
+	}
+
+	// Configuration can be created again
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	mustWrite(t, configFile, "verbose: [true, true]\n")
+
+	if err := confManager.Reload(ctx, name); err == nil {
+		t.Errorf("validators should still apply to the configuration created again")
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer shutdownCancel()
+
+	if err := confManager.Shutdown(shutdownCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); !errors.Is(err, ErrManagerClosed) {
+		t.Errorf("expected ErrManagerClosed, got %v", err)
+	}
+}
+
+func TestCloseDuringFailedMakeConfig(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "failing"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+
+	// The initial load fails once Close has removed the configuration
+	confManager.AddApplierCtx(name, func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+		for confManager.GetWatcher(name) != nil {
+			time.Sleep(time.Millisecond)
+		}
+
+		return errors.New("failed")
+	})
+
+	errc := make(chan error, 1)
+	go func() { errc <- confManager.MakeConfig(ctx, name, &MyConfig{}) }()
+
+	for confManager.GetWatcher(name) == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- confManager.Close() }()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	if err := <-errc; err == nil {
+		t.Errorf("expected MakeConfig to fail")
+	}
+}
+
+func TestCloseWithUnreadChans(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "unread"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	view, err := confManager.AddView(name, func(conf Config) (interface{}, error) {
+		return len(conf.(*MyConfig).Verbose), nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody reads these channels, the reload blocks until Close
+	view.Subscribe()
+	confManager.NewConfigChan(name)
+
+	setArgs(t, "-vv")
+	go func() { _ = confManager.Reload(ctx, name) }()
+
+	for len(confManager.GetConfig(name).(*MyConfig).Verbose) != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- confManager.Close() }()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// memFS is an in-memory FS holding regular files only.
+type memFS map[string][]byte
+
+func (fs memFS) ReadFile(name string) ([]byte, error) {
+	content, ok := fs[name]
+
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	return content, nil
+}
+
+func (fs memFS) Stat(name string) (os.FileInfo, error) {
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrInvalid}
+}
+
+func (fs memFS) Lstat(name string) (os.FileInfo, error) {
+	return fs.Stat(name)
+}
+
+func (fs memFS) Readlink(name string) (string, error) {
+	return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrInvalid}
+}
+
+func TestNewManager(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-f", "/virtual/config.conf")
+
+	fsys := memFS{"/virtual/config.conf": []byte(`{"verbose": [true, true]}`)}
+
+	confManager := NewManager(
+		WithLogger(newQuietLogger(t)),
+		WithFS(fsys),
+		WithFormat(".conf", parseJSON),
+	)
+	defer confManager.Close()
+
+	if err := confManager.MakeConfig(ctx, "custom", &MyConfig{}, WithPolling()); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := len(confManager.GetConfig("custom").(*MyConfig).Verbose); v != 2 {
+		t.Errorf("expected verbose to be 2, got %d", v)
+	}
+
+	// Managers are independent from each other, unknown extensions are
+	// parsed with the default format
+	other := NewManager(WithFS(fsys), WithDefaultFormat(".json"))
+	defer other.Close()
+
+	if other.GetConfig("custom") != nil {
+		t.Errorf("configuration should not exist in another manager")
+	}
+
+	if err := other.MakeConfig(ctx, "custom", &MyConfig{}, WithPolling()); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := len(other.GetConfig("custom").(*MyConfig).Verbose); v != 2 {
+		t.Errorf("expected verbose to be 2, got %d", v)
+	}
+}
+
+func TestGetManager(t *testing.T) {
+	logger := newQuietLogger(t)
+
+	if GetManager(logger) != GetManager(nil) {
+		t.Errorf("GetManager should always return the same manager")
+	}
+}
+
+func TestInvalidCLIOptions(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "cli"
+	confManager := NewManager()
+	defer confManager.Close()
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Parse errors do not rely on the logger to stop the process
+	setArgs(t, "--no-such-flag", "-vv")
+
+	if err := confManager.Reload(ctx, name); err == nil {
+		t.Errorf("expected reloading with an unknown option to fail")
+	}
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 1 {
+		t.Errorf("expected verbose to be 1, got %d", v)
+	}
+
+	if err := confManager.MakeConfig(ctx, "other", &MyConfig{}); err == nil {
+		t.Errorf("expected creating a configuration with an unknown option to fail")
+	}
+}
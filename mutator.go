@@ -0,0 +1,49 @@
+package config
+
+import "fmt"
+
+// Mutator is a function type which normalizes a freshly loaded configuration,
+// e.g. lower-cases hostnames, fills derived defaults or resolves relative
+// paths. currentConfig is nil when the configuration is loaded for the first
+// time and must not be modified.
+//
+// Mutators are run one at a time, in the order they have been registered,
+// once the configuration has been loaded from all its sources and before the
+// changes are computed and the validators are run. An error prevents the new
+// configuration from being applied.
+type Mutator func(currentConfig Config, newConfig Config) error
+
+// AddMutators registers mutators for the named configuration.
+func (m *Manager) AddMutators(name interface{}, mutators ...Mutator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.mutators == nil {
+		m.mutators = make(map[interface{}][]Mutator)
+	}
+
+	m.mutators[name] = append(m.mutators[name], mutators...)
+}
+
+// runMutators runs the mutators registered for the named configuration and
+// returns the first error.
+func (m *Manager) runMutators(name interface{}, currentConfig Config, newConfig Config) error {
+	m.mu.RLock()
+	mutators := m.mutators[name]
+	m.mu.RUnlock()
+
+	for _, mutator := range mutators {
+		if err := callMutator(mutator, currentConfig, newConfig); err != nil {
+			return fmt.Errorf("error while mutating new conf: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// callMutator runs mutator, converting its panic, if any, into an error.
+func callMutator(mutator Mutator, currentConfig Config, newConfig Config) (err error) {
+	defer recoverPanic(&err)
+
+	return mutator(currentConfig, newConfig)
+}
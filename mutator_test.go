@@ -0,0 +1,73 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMutators(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "mutators"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	var order []string
+
+	// Caps verbosity
+	confManager.AddMutators(name, func(currentConfig Config, newConfig Config) error {
+		order = append(order, "cap")
+		conf := newConfig.(*MyConfig)
+
+		if len(conf.Verbose) > 3 {
+			conf.Verbose = conf.Verbose[:3]
+		}
+
+		return nil
+	}, func(currentConfig Config, newConfig Config) error {
+		order = append(order, "check")
+
+		if len(newConfig.(*MyConfig).Verbose) == 2 {
+			return errors.New("verbosity 2 is not supported")
+		}
+
+		return nil
+	})
+
+	confManager.AddValidators(name, func(currentConfig Config, newConfig Config) []error {
+		order = append(order, "validate")
+
+		if v := len(newConfig.(*MyConfig).Verbose); v > 3 {
+			t.Errorf("validators should see the mutated config, verbose is %d", v)
+		}
+
+		return nil
+	})
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCalls(t, order, []string{"cap", "check", "validate"})
+
+	setArgs(t, "-vvvvv")
+	if err := confManager.Reload(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 3 {
+		t.Errorf("expected verbose to be capped to 3, got %d", v)
+	}
+
+	setArgs(t, "-vv")
+	if err := confManager.Reload(ctx, name); err == nil {
+		t.Errorf("expected the mutator error to fail the reload")
+	}
+}
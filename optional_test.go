@@ -0,0 +1,56 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOptionalFile(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	setArgs(t, "-v", "-f", configFile)
+
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	// Missing file is an error unless the file is optional
+	if err := confManager.MakeConfig(ctx, "mandatory", &MyConfig{}); err == nil {
+		t.Fatal("expected an error on missing config file")
+	}
+
+	name := "optional"
+	err := confManager.MakeConfig(ctx, name, &MyConfig{}, WithOptionalFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 1 {
+		t.Errorf("expected verbose to be 1, got %d", v)
+	}
+
+	err = confManager.WaitForFile(ctx, name, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- confManager.WaitForFile(ctx, name, 5*time.Second) }()
+
+	mustWrite(t, configFile, `{"verbose": [true, true, true]}`)
+
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 3 {
+		t.Errorf("expected verbose to be 3, got %d", v)
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"time"
+)
+
+// Option customizes a Manager created by NewManager.
+type Option func(m *Manager)
+
+// WithLogger sets the logger used by the Manager. Nothing is logged by default.
+func WithLogger(logger Logger) Option {
+	return func(m *Manager) {
+		if logger != nil {
+			m.logger = logger
+		}
+	}
+}
+
+// WithClock sets the clock used by the Manager. It defaults to the system clock.
+func WithClock(clock Clock) Option {
+	return func(m *Manager) {
+		m.clock = clock
+	}
+}
+
+// WithFS sets the filesystem from which config files are read. It defaults to
+// the filesystem of the operating system.
+func WithFS(fs FS) Option {
+	return func(m *Manager) {
+		m.fs = fs
+	}
+}
+
+// WithFormat registers the parser used for config files with the given
+// extension (e.g. ".ini"). It can override the parsers of the formats
+// supported out of the box: ".yaml", ".yml", ".json" and ".toml".
+func WithFormat(ext string, parser Parser) Option {
+	return func(m *Manager) {
+		if m.parsers == nil {
+			m.parsers = make(map[string]Parser)
+		}
+
+		m.parsers[ext] = parser
+	}
+}
+
+// WithDefaultFormat sets the extension of the format used to parse config files
+// whose extension is unknown, e.g. ".yaml". The content of such files is
+// ignored by default.
+func WithDefaultFormat(ext string) Option {
+	return func(m *Manager) {
+		m.defaultFormat = ext
+	}
+}
+
+// WithReloadPolicy sets the policy applied by default to the configurations of
+// the Manager. It defaults to DefaultReloadPolicy.
+func WithReloadPolicy(policy ReloadPolicy) Option {
+	return func(m *Manager) {
+		m.reloadPolicy = &policy
+	}
+}
+
+// ConfigOption customizes the way a configuration is handled by the Manager.
+type ConfigOption func(w *watcher)
+
+// WithRestartPolicy sets the policy enforced when a reload changes fields tagged
+// with `reload:"restart"`. It defaults to the one of the Manager's ReloadPolicy.
+func WithRestartPolicy(policy RestartPolicy) ConfigOption {
+	return func(w *watcher) {
+		w.restartPolicy = policy
+	}
+}
+
+// WithPolling makes the Manager poll the config file for changes instead of
+// relying on filesystem notifications, which do not work on some filesystems
+// (e.g. NFS). Polling is automatically used when filesystem notifications can
+// not be set up.
+func WithPolling() ConfigOption {
+	return func(w *watcher) {
+		w.polling = true
+	}
+}
+
+// WithPollInterval sets the interval at which the config file is checked when
+// polling is used. It defaults to DefaultPollInterval.
+func WithPollInterval(interval time.Duration) ConfigOption {
+	return func(w *watcher) {
+		w.pollInterval = interval
+	}
+}
+
+// WithOptionalFile allows the config file to be missing. The configuration is
+// then created from its default values and the file is loaded as soon as it
+// is created. See Manager.WaitForFile.
+func WithOptionalFile() ConfigOption {
+	return func(w *watcher) {
+		w.optional = true
+	}
+}
+
+// WithTimeouts sets the maximum durations of the execution of the validators
+// and of the appliers, 0 meaning no timeout. The context given to validators
+// and appliers is canceled when their stage times out, and the reload fails.
+// The next reload waits for the stage which timed out to return. They default
+// to the ones of the Manager's ReloadPolicy.
+func WithTimeouts(validate time.Duration, apply time.Duration) ConfigOption {
+	return func(w *watcher) {
+		w.validateTimeout = validate
+		w.applyTimeout = apply
+	}
+}
+
+// WithParallelAppliers makes appliers which do not depend on each other run
+// in parallel, see AddNamedApplier. Appliers are run one at a time by default.
+func WithParallelAppliers() ConfigOption {
+	return func(w *watcher) {
+		w.parallelAppliers = true
+	}
+}
+
+// WithValidatorConcurrency sets the maximum number of validators run at the
+// same time. By default, up to GOMAXPROCS validators run concurrently, use a
+// concurrency of 1 to run them one at a time.
+func WithValidatorConcurrency(concurrency int) ConfigOption {
+	return func(w *watcher) {
+		w.validatorConcurrency = concurrency
+	}
+}
+
+// WithWarningsAsErrors makes warnings returned by validators block reloads the
+// same way errors do, e.g. to check configuration files in CI. Notices never
+// block reloads.
+func WithWarningsAsErrors() ConfigOption {
+	return func(w *watcher) {
+		w.warningsAsErrors = true
+	}
+}
+
+// WithRetry sets the policy used to retry failures to load or parse the
+// config file, see RetryPolicy. It defaults to the one of the Manager's
+// ReloadPolicy.
+func WithRetry(policy RetryPolicy) ConfigOption {
+	return func(w *watcher) {
+		w.retryPolicy = policy
+	}
+}
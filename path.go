@@ -0,0 +1,290 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fieldName returns the name used to designate a struct field in field paths.
+// It uses the name given in the yaml, json or toml tag (in that order) and
+// falls back to the lower cased name of the field.
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"yaml", "json", "toml"} {
+		name := strings.Split(field.Tag.Get(key), ",")[0]
+
+		if len(name) > 0 && name != "-" {
+			return name
+		}
+	}
+
+	return strings.ToLower(field.Name)
+}
+
+// isInlined returns true if the fields of the given struct field should be
+// considered as belonging to its parent, which is the case of embedded structs
+// which have not been explicitly named.
+func isInlined(field reflect.StructField) bool {
+	if !field.Anonymous {
+		return false
+	}
+
+	for _, key := range []string{"yaml", "json", "toml"} {
+		name := strings.Split(field.Tag.Get(key), ",")[0]
+
+		if len(name) > 0 && name != "-" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// joinPath appends a field name to a field path.
+func joinPath(parent string, name string) string {
+	if len(parent) == 0 {
+		return name
+	}
+
+	return parent + "." + name
+}
+
+// indexPath appends a slice index to a field path.
+func indexPath(parent string, i int) string {
+	return fmt.Sprintf("%s[%d]", parent, i)
+}
+
+// keyPath appends a map key to a field path.
+func keyPath(parent string, key reflect.Value) string {
+	return fmt.Sprintf("%s[%v]", parent, key.Interface())
+}
+
+// sortedMapKeys returns the keys of a map sorted by their string
+// representation so that maps are always walked in the same order.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	return keys
+}
+
+// valueAtPath returns the value of the field designated by path in conf, e.g.
+// `database.replicas[2]`. It returns nil if a pointer on the path is nil.
+func valueAtPath(conf Config, path string) (interface{}, error) {
+	v := reflect.ValueOf(conf)
+	rest := path
+
+	for len(rest) > 0 {
+		if v = indirect(v); !v.IsValid() {
+			return nil, nil
+		}
+
+		var segment string
+
+		if rest[0] == '[' {
+			end := strings.Index(rest, "]")
+
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path `%s`: missing `]`", path)
+			}
+
+			segment, rest = rest[1:end], rest[end+1:]
+
+			var err error
+			if v, err = indexValue(v, segment); err != nil {
+				return nil, fmt.Errorf("invalid path `%s`: %v", path, err)
+			}
+
+			continue
+		}
+
+		rest = strings.TrimPrefix(rest, ".")
+		end := strings.IndexAny(rest, ".[")
+
+		if end < 0 {
+			end = len(rest)
+		}
+
+		segment, rest = rest[:end], rest[end:]
+
+		if v.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("invalid path `%s`: `%s` is not a field of %s", path, segment, v.Type())
+		}
+
+		field, ok := fieldByName(v, segment)
+
+		if !ok {
+			return nil, fmt.Errorf("invalid path `%s`: no field `%s` in %s", path, segment, v.Type())
+		}
+
+		v = field
+	}
+
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	return v.Interface(), nil
+}
+
+// checkPath checks that path designates a field of the type t, so that invalid
+// paths can be reported before any value of that type exists. Parts of the path
+// below interface fields can not be checked and are accepted.
+func checkPath(t reflect.Type, path string) error {
+	rest := path
+
+	for len(rest) > 0 {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if t.Kind() == reflect.Interface {
+			return nil
+		}
+
+		var segment string
+
+		if rest[0] == '[' {
+			end := strings.Index(rest, "]")
+
+			if end < 0 {
+				return fmt.Errorf("invalid path `%s`: missing `]`", path)
+			}
+
+			segment, rest = rest[1:end], rest[end+1:]
+
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array:
+				if i, err := strconv.Atoi(segment); err != nil || i < 0 {
+					return fmt.Errorf("invalid path `%s`: invalid index `%s`", path, segment)
+				}
+			case reflect.Map:
+			default:
+				return fmt.Errorf("invalid path `%s`: %s can not be indexed", path, t)
+			}
+
+			t = t.Elem()
+
+			continue
+		}
+
+		rest = strings.TrimPrefix(rest, ".")
+		end := strings.IndexAny(rest, ".[")
+
+		if end < 0 {
+			end = len(rest)
+		}
+
+		segment, rest = rest[:end], rest[end:]
+
+		if t.Kind() != reflect.Struct {
+			return fmt.Errorf("invalid path `%s`: `%s` is not a field of %s", path, segment, t)
+		}
+
+		field, ok := typeFieldByName(t, segment)
+
+		if !ok {
+			return fmt.Errorf("invalid path `%s`: no field `%s` in %s", path, segment, t)
+		}
+
+		t = field.Type
+	}
+
+	return nil
+}
+
+// typeFieldByName is the counterpart of fieldByName working on struct types.
+func typeFieldByName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+
+		if isInlined(field) {
+			ft := field.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				if found, ok := typeFieldByName(ft, name); ok {
+					return found, true
+				}
+			}
+
+			continue
+		}
+
+		if fieldName(field) == name {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// fieldByName looks for the field of the struct v whose path name is name,
+// including fields of inlined embedded structs.
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+
+		if isInlined(field) {
+			if fv := indirect(v.Field(i)); fv.IsValid() && fv.Kind() == reflect.Struct {
+				if found, ok := fieldByName(fv, name); ok {
+					return found, true
+				}
+			}
+
+			continue
+		}
+
+		if fieldName(field) == name {
+			return v.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// indexValue returns the element of the slice, array or map v designated by
+// the index or key given as a string.
+func indexValue(v reflect.Value, index string) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(index)
+
+		if err != nil || i < 0 {
+			return reflect.Value{}, fmt.Errorf("invalid index `%s`", index)
+		}
+
+		if i >= v.Len() {
+			return reflect.Value{}, nil
+		}
+
+		return v.Index(i), nil
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			if fmt.Sprint(key.Interface()) == index {
+				return v.MapIndex(key), nil
+			}
+		}
+
+		return reflect.Value{}, nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("%s can not be indexed", v.Type())
+}
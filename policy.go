@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+// ReloadPolicy holds the policies applied by default to the configurations of
+// a Manager. They can be overridden for each configuration with ConfigOptions.
+type ReloadPolicy struct {
+	// Restart is the policy enforced when a reload changes fields tagged
+	// with `reload:"restart"`.
+	Restart RestartPolicy
+	// Retry is the policy used to retry failures to load the config file.
+	Retry RetryPolicy
+	// ValidateTimeout bounds the execution of the validators, 0 means no
+	// timeout.
+	ValidateTimeout time.Duration
+	// ApplyTimeout bounds the execution of the appliers, 0 means no timeout.
+	ApplyTimeout time.Duration
+	// WarningsAsErrors makes warnings block reloads, e.g. in CI.
+	WarningsAsErrors bool
+}
+
+// DefaultReloadPolicy is the reload policy used when none has been given.
+var DefaultReloadPolicy = ReloadPolicy{
+	Restart: RestartPolicyReject,
+	Retry:   DefaultRetryPolicy,
+}
+
+// policy returns the reload policy of the Manager.
+func (m *Manager) policy() ReloadPolicy {
+	if m.reloadPolicy == nil {
+		return DefaultReloadPolicy
+	}
+
+	return *m.reloadPolicy
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"time"
+)
+
+// DefaultPollInterval is the interval at which config files are checked for
+// changes when polling is used.
+const DefaultPollInterval = 5 * time.Second
+
+// fileState is what is compared between two polls of a file.
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+	hash    []byte
+}
+
+// equal returns true if both states are the same.
+func (s fileState) equal(o fileState) bool {
+	return s.exists == o.exists &&
+		s.modTime.Equal(o.modTime) &&
+		s.size == o.size &&
+		bytes.Equal(s.hash, o.hash)
+}
+
+// statFile returns the current state of the file.
+func statFile(fsys FS, filename string) (fileState, error) {
+	info, err := fsys.Stat(filename)
+
+	if os.IsNotExist(err) {
+		return fileState{}, nil
+	} else if err != nil {
+		return fileState{}, err
+	}
+
+	content, err := fsys.ReadFile(filename)
+
+	if err != nil {
+		return fileState{}, err
+	}
+
+	hash := sha256.Sum256(content)
+
+	return fileState{
+		exists:  true,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		hash:    hash[:],
+	}, nil
+}
+
+// startPolling returns the state of the config file which changes are then
+// looked for by pollConfigFile.
+func (w *watcher) startPolling() fileState {
+	configFile := w.initialConfig.ConfigFile()
+
+	w.logger.Debugf("Polling config file `%s` every %s", configFile, w.interval())
+
+	last, err := statFile(w.fs, configFile)
+
+	if err != nil {
+		w.logger.Errorf("poll: %s", err)
+	}
+
+	return last
+}
+
+// interval returns the polling interval.
+func (w *watcher) interval() time.Duration {
+	if w.pollInterval <= 0 {
+		return DefaultPollInterval
+	}
+
+	return w.pollInterval
+}
+
+// pollConfigFile periodically checks the config file and reloads the
+// configuration when its modification time, size or content has changed
+// from last.
+func (w *watcher) pollConfigFile(ctx context.Context, last fileState) {
+	configFile := w.initialConfig.ConfigFile()
+	interval := w.interval()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Debugf("poll: context closed")
+			return
+		case <-w.clock.After(interval):
+			state, err := statFile(w.fs, configFile)
+
+			if err != nil {
+				w.logger.Errorf("poll: %s", err)
+				continue
+			}
+
+			if state.equal(last) {
+				continue
+			}
+
+			last = state
+
+			if !state.exists {
+				w.logger.Debugf("Config file removed")
+				continue
+			}
+
+			w.logger.Debugf("Config file changed")
+
+			if err := w.reload(ctx, ReasonPoll); err != nil {
+				w.logger.Errorf("%v", err)
+			}
+		}
+	}
+}
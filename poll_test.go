@@ -0,0 +1,59 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPollConfigFile(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+
+	err := ioutil.WriteFile(configFile, []byte("verbose: [true]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setArgs(t, "-f", configFile)
+
+	name := "poll"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	err = confManager.MakeConfig(ctx, name, &MyConfig{}, WithPolling(), WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := confManager.GetWatcher(name); w.fsWatcher != nil {
+		t.Errorf("fsnotify should not be used when polling")
+	}
+
+	events := confManager.NewReloadChan(name)
+
+	err = ioutil.WriteFile(configFile, []byte("verbose: [true, true]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Reason != ReasonPoll {
+			t.Errorf("expected reason %s, got %s", ReasonPoll, event.Reason)
+		}
+
+		if v := len(event.Config.(*MyConfig).Verbose); v != 2 {
+			t.Errorf("expected verbose to be 2, got %d", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No reload event received")
+	}
+}
@@ -0,0 +1,374 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Reloads of a configuration are run one at a time by a dedicated go routine,
+// the reload executor. Triggers received while a reload is running are
+// coalesced into a single reload which starts once the running one is over,
+// and whose result is returned to all of them.
+//
+// A reload which is applied guarantees that:
+//   - all the appliers have been prepared before the new configuration is
+//     returned by GetConfig,
+//   - the new configuration is returned by GetConfig before it is sent to the
+//     channels returned by NewConfigChan, NewReloadChan and Subscribe,
+//   - all the channels have received the new configuration before two-phase
+//     appliers are committed,
+//   - all the appliers have been committed before the next reload starts,
+//     including the ones of a stage which timed out.
+
+// Reason tells why a configuration is being loaded.
+type Reason int
+
+const (
+	// ReasonInitial is the first load of the configuration by MakeConfig.
+	ReasonInitial Reason = iota
+	// ReasonFSEvent is a reload triggered by a change of the config file.
+	ReasonFSEvent
+	// ReasonSignal is a reload triggered by a signal.
+	ReasonSignal
+	// ReasonAPI is a reload triggered by a call to Manager.Reload.
+	ReasonAPI
+	// ReasonPoll is a reload triggered by the polling of the config file.
+	ReasonPoll
+	// ReasonDependency is a reload triggered because something the
+	// configuration depends on has changed.
+	ReasonDependency
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonInitial:
+		return "initial"
+	case ReasonFSEvent:
+		return "fs event"
+	case ReasonSignal:
+		return "signal"
+	case ReasonAPI:
+		return "api"
+	case ReasonPoll:
+		return "poll"
+	case ReasonDependency:
+		return "dependency"
+	}
+
+	return fmt.Sprintf("Reason(%d)", int(r))
+}
+
+// ValidationError is returned when a new configuration has not been applied
+// because validation errors have been found.
+type ValidationError struct {
+	Errors []error
+	// Report details which validators returned the errors.
+	Report *ValidationReport
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("new configuration not applied because error(s) have been found: %s", strings.Join(msgs, "; "))
+}
+
+// Reload synchronously reloads the named configuration and returns the error
+// which prevented the new configuration from being applied, if any. If a
+// reload is already running, a new one is queued. ctx only bounds the wait,
+// the reload is not canceled when ctx is done.
+func (m *Manager) Reload(ctx context.Context, name interface{}) error {
+	return m.ReloadWithReason(ctx, name, ReasonAPI)
+}
+
+// ReloadWithReason is like Reload but lets the caller tell why the
+// configuration is reloaded, e.g. ReasonDependency.
+func (m *Manager) ReloadWithReason(ctx context.Context, name interface{}, reason Reason) error {
+	w := m.GetWatcher(name)
+
+	if w == nil {
+		return fmt.Errorf("configuration `%v` does not exist", name)
+	}
+
+	return w.reload(ctx, reason)
+}
+
+// reloadRequest is a reload waiting to be run by the reload executor. All the
+// triggers coalesced into the request share its result.
+type reloadRequest struct {
+	reason Reason
+	done   chan struct{}
+	err    error
+}
+
+// reload queues a reload of the configuration and waits for its result. The
+// reason of a reload which has been coalesced with others is the reason of the
+// first trigger.
+func (w *watcher) reload(ctx context.Context, reason Reason) error {
+	w.queueMu.Lock()
+
+	if w.queueErr != nil {
+		w.queueMu.Unlock()
+		return w.queueErr
+	}
+
+	if w.pending == nil {
+		w.pending = &reloadRequest{reason: reason, done: make(chan struct{})}
+
+		select {
+		case w.trigger <- struct{}{}:
+		default:
+		}
+	} else {
+		w.logger.Debugf("Reload (reason: %s) coalesced with queued reload", reason)
+	}
+
+	req := w.pending
+	w.queueMu.Unlock()
+
+	select {
+	case <-req.done:
+		return req.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runExecutor runs the queued reloads one at a time until ctx is done or the
+// watcher is stopped. Queued reloads which have not been run fail.
+func (w *watcher) runExecutor(ctx context.Context) {
+	defer close(w.executorDone)
+
+	for {
+		var err error
+
+		select {
+		case <-w.trigger:
+		case <-w.stopped:
+			err = fmt.Errorf("configuration `%v` has been deleted", w.name)
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		if err == nil {
+			err = w.waitLastStage(ctx)
+		}
+
+		w.queueMu.Lock()
+		req := w.pending
+		w.pending = nil
+
+		if err != nil {
+			w.queueErr = err
+		}
+		w.queueMu.Unlock()
+
+		if req != nil {
+			if err == nil {
+				req.err = w.runReload(ctx, req.reason)
+			} else {
+				req.err = err
+			}
+
+			close(req.done)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// runStage runs a stage of the reload, see runStage. The stage is remembered so
+// that the next reload waits for it to return if it timed out.
+func (w *watcher) runStage(ctx context.Context, stage Stage, timeout time.Duration, info *ReloadInfo, f func(ctx context.Context) error, abandoned func(err error)) error {
+	w.lastStage = make(chan struct{})
+
+	return runStage(ctx, stage, timeout, info, f, abandoned, w.lastStage)
+}
+
+// waitLastStage waits for the last stage of the previous reload to return, as
+// stages which time out keep running in the background, so that the stages of
+// two reloads never run at the same time.
+func (w *watcher) waitLastStage(ctx context.Context) error {
+	if w.lastStage == nil {
+		return nil
+	}
+
+	select {
+	case <-w.lastStage:
+		return nil
+	case <-w.stopped:
+		return fmt.Errorf("configuration `%v` has been deleted", w.name)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runReload loads, validates and applies a new version of the configuration.
+// It returns the error which prevented the new configuration from being
+// applied. It must only be called by the reload executor.
+func (w *watcher) runReload(ctx context.Context, reason Reason) (err error) {
+	var warnings, commitErrs []error
+	defer func() { w.manager.recordStatus(w.name, reason, err, warnings, commitErrs) }()
+
+	currentConfig := w.config
+
+	if currentConfig != nil {
+		w.logger.Infof("Reloading config (reason: %s)", reason)
+	}
+
+	// Every load starts from the config given to MakeConfig and its defaults
+	base := copyConfig(w.baseConfig)
+
+	if err := applyDefaults(base); err != nil {
+		return err
+	}
+
+	// Load config from cli args and then from config file if exists
+	newConfig, err := w.loadConfigWithRetry(ctx, func() Config {
+		return copyConfig(base)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// The first load ends up in the config given to MakeConfig
+	if currentConfig == nil {
+		if err := assignConfig(w.initialConfig, newConfig); err != nil {
+			return err
+		}
+
+		newConfig = w.initialConfig
+	}
+
+	// Normalize the new configuration
+	if err := w.manager.runMutators(w.name, currentConfig, newConfig); err != nil {
+		return err
+	}
+
+	// Check fields which can not be changed at runtime
+	report := &ValidationReport{warningsAsErrors: w.warningsAsErrors}
+	if err := w.checkRestartFields(newConfig); err != nil {
+		report.add(ReportRestart, err)
+	}
+
+	// Compute changes once for the whole pipeline
+	info := &ReloadInfo{Name: w.name, Reason: reason, Changes: diffConfigs(currentConfig, newConfig)}
+	w.logger.Debugf("Configuration changes: %v", info.Changes)
+
+	// Execute validators
+	var results []ValidatorReport
+	err = w.runStage(ctx, StageValidate, w.validateTimeout, info, func(ctx context.Context) error {
+		results = w.manager.runValidators(ctx, w.name, w.validatorConcurrency, currentConfig, newConfig, info)
+		return nil
+	}, nil)
+
+	if err != nil {
+		report.add(ReportStage, err)
+	} else {
+		for _, result := range results {
+			report.add(result.Name, result.Errors...)
+		}
+	}
+
+	// Compute views
+	var views []viewValue
+	if report.OK() {
+		var errs []error
+		views, errs = w.manager.deriveViews(w.name, newConfig)
+		report.add(ReportViews, errs...)
+	}
+
+	for _, v := range report.Validators {
+		for _, warning := range v.Warnings {
+			if SeverityOf(warning) == SeverityNotice {
+				w.logger.Infof("Notice while validating new conf (%s): %v", v.Name, warning)
+			} else {
+				w.logger.Warnf("Warning while validating new conf (%s): %v", v.Name, warning)
+			}
+		}
+	}
+
+	warnings = report.Warnings()
+	info.Warnings = warnings
+
+	if !report.OK() {
+		for _, v := range report.Validators {
+			for _, err := range v.Errors {
+				w.logger.Errorf("Error while validating new conf (%s): %v", v.Name, err)
+			}
+		}
+
+		return &ValidationError{Errors: report.Errors(), Report: report}
+	}
+
+	// Prepare appliers, the ones prepared by a stage which timed out are
+	// aborted once the stage returns
+	abortCtx := context.WithValue(ctx, reloadInfoKey{}, info)
+
+	var prepared []registeredApplier
+	err = w.runStage(ctx, StageApply, w.applyTimeout, info, func(ctx context.Context) error {
+		var err error
+		prepared, err = w.manager.prepareAppliers(ctx, abortCtx, w.name, w.parallelAppliers, currentConfig, newConfig, info)
+		return err
+	}, func(err error) {
+		if err == nil {
+			w.manager.abortAppliers(abortCtx, prepared, currentConfig, newConfig, info)
+		}
+	})
+
+	if err != nil {
+		return fmt.Errorf("error while applying new conf: %w", err)
+	}
+
+	// Update current configuration
+	w.manager.setConfig(w.name, newConfig)
+
+	if currentConfig != nil {
+		w.manager.broadcastNewConfig(w.name, currentConfig, info)
+	}
+
+	commitViews(views, w.stopped)
+
+	// Commit appliers, their errors do not make the applied reload fail
+	var committed []error
+	err = w.runStage(ctx, StageCommit, w.applyTimeout, info, func(ctx context.Context) error {
+		committed = w.manager.commitAppliers(ctx, prepared, currentConfig, newConfig, info)
+		return nil
+	}, nil)
+
+	if err != nil {
+		w.logger.Errorf("Error while committing new conf: %v", err)
+		commitErrs = []error{err}
+	} else {
+		commitErrs = committed
+	}
+
+	if w.fileRead {
+		w.markFileLoaded()
+	}
+
+	return nil
+}
+
+// assignConfig overwrites the content of dst with the content of src, both
+// being pointers to the same type.
+func assignConfig(dst Config, src Config) error {
+	dv, sv := reflect.ValueOf(dst), reflect.ValueOf(src)
+
+	if dv.Kind() != reflect.Ptr || dv.IsNil() || sv.Type() != dv.Type() || sv.IsNil() {
+		return fmt.Errorf("can not load configuration into %T", dst)
+	}
+
+	dv.Elem().Set(sv.Elem())
+
+	return nil
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// quietLogger is a Logger which does not fail the test on errors, for tests
+// expecting errors to be logged.
+type quietLogger struct {
+	test   *testing.T
+	closed *atomic.Bool
+}
+
+func newQuietLogger(t *testing.T) *quietLogger {
+	l := &quietLogger{t, atomic.NewBool(false)}
+
+	// Background go routines might want to log after the test is finished
+	t.Cleanup(func() { l.closed.Store(true) })
+
+	return l
+}
+
+func (l *quietLogger) logf(format string, vals ...interface{}) {
+	if !l.closed.Load() {
+		l.test.Logf("go-libqd/config: "+format, vals...)
+	}
+}
+
+func (l *quietLogger) Tracef(format string, vals ...interface{}) { l.logf(format, vals...) }
+func (l *quietLogger) Debugf(format string, vals ...interface{}) { l.logf(format, vals...) }
+func (l *quietLogger) Infof(format string, vals ...interface{})  { l.logf(format, vals...) }
+func (l *quietLogger) Warnf(format string, vals ...interface{})  { l.logf(format, vals...) }
+func (l *quietLogger) Errorf(format string, vals ...interface{}) { l.logf(format, vals...) }
+
+// Fatalf fails the test without stopping it as it is called from the reload
+// go routines, where t.Fatalf can not be used.
+func (l *quietLogger) Fatalf(format string, vals ...interface{}) {
+	if !l.closed.Load() {
+		l.test.Errorf(format, vals...)
+	}
+}
+
+func mustMkdir(t *testing.T, dir string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustWrite(t *testing.T, filename string, content string) {
+	t.Helper()
+
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setArgs overrides os.Args for the duration of the test.
+func setArgs(t *testing.T, args ...string) {
+	orig := os.Args
+	os.Args = append([]string{"test"}, args...)
+	t.Cleanup(func() { os.Args = orig })
+}
+
+func TestReload(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-vv")
+
+	name := "reload"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+	confManager.AddValidators(name, myConfigValidator)
+
+	var reasons []Reason
+	confManager.AddInfoValidators(name, func(currentConfig Config, newConfig Config, info *ReloadInfo) []error {
+		reasons = append(reasons, info.Reason)
+		return nil
+	})
+
+	err := confManager.MakeConfig(ctx, name, &MyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := confManager.NewReloadChan(name)
+	errc := make(chan error, 1)
+
+	setArgs(t, "-vvv")
+	go func() { errc <- confManager.Reload(ctx, name) }()
+
+	select {
+	case event := <-events:
+		if event.Reason != ReasonAPI {
+			t.Errorf("expected reason %s, got %s", ReasonAPI, event.Reason)
+		}
+
+		if !event.Changes.Has("verbose") {
+			t.Errorf("expected changes on verbose, got %v", event.Changes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No reload event received")
+	}
+
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 3 {
+		t.Errorf("expected verbose to be 3, got %d", v)
+	}
+
+	// Invalid configuration
+	setArgs(t, "-vvvvvvv")
+	err = confManager.Reload(ctx, name)
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 3 {
+		t.Errorf("invalid configuration should not have been applied, verbose is %d", v)
+	}
+
+	expected := []Reason{ReasonInitial, ReasonAPI, ReasonAPI}
+	if len(reasons) != len(expected) || reasons[0] != expected[0] || reasons[2] != expected[2] {
+		t.Errorf("expected reasons %v, got %v", expected, reasons)
+	}
+
+	if err := confManager.Reload(ctx, "unknown"); err == nil {
+		t.Errorf("reloading an unknown configuration should fail")
+	}
+}
+
+func TestReloadQueue(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "queue"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	applied := atomic.NewInt32(0)
+	running := atomic.NewInt32(0)
+	release := make(chan struct{})
+
+	confManager.AddAppliers(name, func(currentConfig Config, newConfig Config) error {
+		if running.Inc() != 1 {
+			t.Errorf("appliers should never run concurrently")
+		}
+		defer running.Dec()
+
+		if applied.Inc() > 1 {
+			<-release
+		}
+
+		return nil
+	})
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	configs := confManager.NewConfigChan(name)
+	errc := make(chan error, 10)
+
+	// The first reload blocks in its applier, the others are coalesced
+	go func() { errc <- confManager.Reload(ctx, name) }()
+
+	for applied.Load() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < 9; i++ {
+		go func() { errc <- confManager.Reload(ctx, name) }()
+	}
+
+	// Give the queued reloads the time to be coalesced
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	received := make(chan int)
+	go func() {
+		n := 0
+		for range configs {
+			n++
+		}
+		received <- n
+	}()
+
+	for i := 0; i < 10; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := confManager.DeleteConfig(name); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := <-received; n != 2 {
+		t.Errorf("expected 2 new configurations, got %d", n)
+	}
+
+	if n := applied.Load(); n != 3 {
+		t.Errorf("expected queued reloads to be coalesced into 1 reload, got %d", n-2)
+	}
+}
+
+func TestLoadedConfigFile(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	mustWrite(t, configFile, "verbose: [true]\n")
+	setArgs(t, "-f", configFile)
+
+	name := "loaded"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}, WithPolling(), WithPollInterval(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	w := confManager.GetWatcher(name)
+
+	// Duplicated notifications of an already loaded file do not reload
+	state, err := statFile(osFS{}, configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.isLoaded(state) {
+		t.Errorf("expected the config file to be loaded")
+	}
+
+	mustWrite(t, configFile, "verbose: [true, true]\n")
+
+	state, err = statFile(osFS{}, configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.isLoaded(state) {
+		t.Errorf("expected the changed config file not to be loaded")
+	}
+
+	if err := confManager.Reload(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.isLoaded(state) {
+		t.Errorf("expected the changed config file to be loaded")
+	}
+}
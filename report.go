@@ -0,0 +1,156 @@
+package config
+
+import (
+	"errors"
+	"sort"
+)
+
+// Names under which the checks made by the Manager itself are reported.
+const (
+	// ReportTags gathers the errors of the `validate` struct tags.
+	ReportTags = "tags"
+	// ReportMethods gathers the errors of the Validate methods of the
+	// configuration and its fields, see SelfValidator.
+	ReportMethods = "methods"
+	// ReportRestart gathers the errors of the fields tagged with
+	// `reload:"restart"`.
+	ReportRestart = "restart"
+	// ReportViews gathers the errors of the derivation of views.
+	ReportViews = "views"
+	// ReportStage gathers the errors of the validation stage itself, e.g.
+	// its timeout.
+	ReportStage = "stage"
+)
+
+// ValidationReport gathers the errors found while validating a new
+// configuration. Its content does not depend on the order in which
+// validators completed.
+type ValidationReport struct {
+	// Validators lists the results of the validators in the order they have
+	// been registered, preceded by the checks made by the Manager itself.
+	Validators []ValidatorReport
+
+	// warningsAsErrors makes warnings block the reload
+	warningsAsErrors bool
+}
+
+// ValidatorReport holds the errors returned by a validator.
+type ValidatorReport struct {
+	// Name is the name of the validator, see AddNamedValidator. Validators
+	// registered without name are named after their registration index,
+	// e.g. `#2`.
+	Name string
+	// Errors lists the errors which block the reload.
+	Errors []error
+	// Warnings lists the warnings and notices, see Warn and Notice.
+	Warnings []error
+}
+
+// add appends the errors of the named validator to the report, sorting them
+// by severity.
+func (r *ValidationReport) add(name string, errs ...error) {
+	if len(errs) == 0 {
+		return
+	}
+
+	var v *ValidatorReport
+
+	for i := range r.Validators {
+		if r.Validators[i].Name == name {
+			v = &r.Validators[i]
+			break
+		}
+	}
+
+	if v == nil {
+		r.Validators = append(r.Validators, ValidatorReport{Name: name})
+		v = &r.Validators[len(r.Validators)-1]
+	}
+
+	for _, err := range errs {
+		severity := SeverityOf(err)
+
+		if severity == SeverityError || (severity == SeverityWarning && r.warningsAsErrors) {
+			v.Errors = append(v.Errors, err)
+		} else {
+			v.Warnings = append(v.Warnings, err)
+		}
+	}
+}
+
+// OK returns true if no error has been found.
+func (r *ValidationReport) OK() bool {
+	return len(r.Errors()) == 0
+}
+
+// Errors returns all the errors of the report which block the reload.
+func (r *ValidationReport) Errors() []error {
+	var errs []error
+
+	for _, v := range r.Validators {
+		errs = append(errs, v.Errors...)
+	}
+
+	return errs
+}
+
+// Warnings returns all the warnings and notices of the report.
+func (r *ValidationReport) Warnings() []error {
+	var warnings []error
+
+	for _, v := range r.Validators {
+		warnings = append(warnings, v.Warnings...)
+	}
+
+	return warnings
+}
+
+// ByValidator returns the errors of the report grouped by validator name.
+// Warnings are not included.
+func (r *ValidationReport) ByValidator() map[string][]error {
+	byValidator := make(map[string][]error)
+
+	for _, v := range r.Validators {
+		if len(v.Errors) > 0 {
+			byValidator[v.Name] = append(byValidator[v.Name], v.Errors...)
+		}
+	}
+
+	return byValidator
+}
+
+// ByPath returns the errors of the report grouped by the path of the field
+// they relate to, see FieldError. Errors which do not relate to a field are
+// grouped under the empty path. Warnings are not included.
+func (r *ValidationReport) ByPath() map[string][]error {
+	byPath := make(map[string][]error)
+
+	for _, err := range r.Errors() {
+		path := ""
+
+		var ferr *FieldError
+		if errors.As(err, &ferr) {
+			path = ferr.Path
+		}
+
+		byPath[path] = append(byPath[path], err)
+	}
+
+	return byPath
+}
+
+// Paths returns the sorted paths of the fields errors relate to.
+func (r *ValidationReport) Paths() []string {
+	byPath := r.ByPath()
+	paths := make([]string, 0, len(byPath))
+
+	for path := range byPath {
+		if len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
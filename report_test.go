@@ -0,0 +1,147 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidationReport(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "report"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	// Both validators wait for each other so they have to run concurrently
+	var barrier sync.WaitGroup
+	barrier.Add(2)
+
+	confManager.AddNamedValidator(name, "dns", func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) []error {
+		barrier.Done()
+		barrier.Wait()
+
+		if info.Reason == ReasonInitial {
+			return nil
+		}
+
+		// Finish last to check that results keep the registration order
+		time.Sleep(10 * time.Millisecond)
+
+		return []error{
+			&FieldError{Path: "database.host", Rule: "dns", Err: errors.New("no such host")},
+			errors.New("dns server unreachable"),
+		}
+	})
+
+	confManager.AddValidators(name, func(currentConfig Config, newConfig Config) []error {
+		barrier.Done()
+		barrier.Wait()
+
+		if len(newConfig.(*MyConfig).Verbose) == 1 {
+			return nil
+		}
+
+		return []error{&FieldError{Path: "database.host", Rule: "schema", Err: errors.New("invalid")}}
+	})
+
+	err := confManager.MakeConfig(ctx, name, &MyConfig{}, WithValidatorConcurrency(2), WithTimeouts(5*time.Second, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	barrier.Add(2)
+	setArgs(t, "-vv")
+	err = confManager.Reload(ctx, name)
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Report == nil {
+		t.Fatalf("expected a validation error with a report, got %v", err)
+	}
+
+	report := verr.Report
+
+	if len(report.Validators) != 2 || report.Validators[0].Name != "dns" || report.Validators[1].Name != "#2" {
+		t.Fatalf("unexpected validators in report %+v", report.Validators)
+	}
+
+	if n := len(report.ByValidator()["dns"]); n != 2 {
+		t.Errorf("expected 2 errors from dns, got %d", n)
+	}
+
+	if n := len(report.ByPath()["database.host"]); n != 2 {
+		t.Errorf("expected 2 errors on database.host, got %d", n)
+	}
+
+	if paths := report.Paths(); len(paths) != 1 || paths[0] != "database.host" {
+		t.Errorf("unexpected paths %v", paths)
+	}
+
+	if len(verr.Errors) != 3 {
+		t.Errorf("expected 3 errors, got %v", verr.Errors)
+	}
+}
+
+func TestValidationWarnings(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	validator := func(currentConfig Config, newConfig Config) []error {
+		return []error{
+			Warn(errors.New("pool size unusually large")),
+			Notice(errors.New("deprecated key")),
+		}
+	}
+
+	confManager.AddValidators("lenient", validator)
+	confManager.AddValidators("strict", validator)
+
+	if err := confManager.MakeConfig(ctx, "lenient", &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	events := confManager.NewReloadChan("lenient")
+	errc := make(chan error, 1)
+	go func() { errc <- confManager.Reload(ctx, "lenient") }()
+
+	select {
+	case event := <-events:
+		if len(event.Warnings) != 2 || SeverityOf(event.Warnings[0]) != SeverityWarning || SeverityOf(event.Warnings[1]) != SeverityNotice {
+			t.Errorf("expected a warning and a notice, got %v", event.Warnings)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No reload event received")
+	}
+
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	if status, _ := confManager.Status("lenient"); len(status.Warnings) != 2 {
+		t.Errorf("expected warnings in status, got %v", status.Warnings)
+	}
+
+	// Warnings block the reload when treated as errors, notices do not
+	err := confManager.MakeConfig(ctx, "strict", &MyConfig{}, WithWarningsAsErrors())
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 || len(verr.Report.Warnings()) != 1 {
+		t.Fatalf("expected a validation error caused by the warning, got %v", err)
+	}
+}
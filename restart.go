@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RestartPolicy defines what happens when a reload changes fields which are
+// tagged with `reload:"restart"`, i.e. fields which can not be changed while
+// the program is running.
+type RestartPolicy int
+
+const (
+	// RestartPolicyReject rejects the whole reload.
+	RestartPolicyReject RestartPolicy = iota
+	// RestartPolicyApplyOthers applies the new configuration but keeps the
+	// current values of the fields requiring a restart.
+	RestartPolicyApplyOthers
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartPolicyReject:
+		return "reject"
+	case RestartPolicyApplyOthers:
+		return "apply-others"
+	}
+
+	return fmt.Sprintf("RestartPolicy(%d)", int(p))
+}
+
+// RestartRequiredError is the error reported when a reload changes fields which
+// require a restart.
+type RestartRequiredError struct {
+	// Paths lists the paths of the fields which require a restart.
+	Paths []string
+}
+
+func (e *RestartRequiredError) Error() string {
+	return fmt.Sprintf("restart required for: %s", strings.Join(e.Paths, ", "))
+}
+
+// restartField is a field tagged with `reload:"restart"` whose value differs
+// between two configurations.
+type restartField struct {
+	path     string
+	oldValue reflect.Value
+	newValue reflect.Value
+}
+
+// restartFields returns the fields tagged with `reload:"restart"` whose values
+// differ between currentConfig and newConfig.
+func restartFields(currentConfig Config, newConfig Config) []restartField {
+	if currentConfig == nil || newConfig == nil {
+		return nil
+	}
+
+	return diffRestartFields(reflect.ValueOf(currentConfig), reflect.ValueOf(newConfig), "")
+}
+
+func diffRestartFields(oldV reflect.Value, newV reflect.Value, path string) []restartField {
+	var fields []restartField
+
+	if oldV.Kind() != newV.Kind() {
+		return nil
+	}
+
+	switch oldV.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		switch {
+		case !oldV.IsNil() && !newV.IsNil():
+			fields = append(fields, diffRestartFields(oldV.Elem(), newV.Elem(), path)...)
+		case oldV.IsNil() != newV.IsNil():
+			// Setting or unsetting a value holding restart fields requires a
+			// restart if these fields are not empty.
+			set := newV
+			if newV.IsNil() {
+				set = oldV
+			}
+
+			if len(diffRestartFields(reflect.Zero(set.Elem().Type()), set.Elem(), path)) > 0 {
+				fields = append(fields, restartField{path, oldV, newV})
+			}
+		}
+	case reflect.Struct:
+		if oldV.Type() != newV.Type() {
+			return nil
+		}
+
+		t := oldV.Type()
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			if len(field.PkgPath) > 0 {
+				continue
+			}
+
+			fpath := path
+			if !isInlined(field) {
+				fpath = joinPath(path, fieldName(field))
+			}
+
+			if field.Tag.Get("reload") == "restart" {
+				if !reflect.DeepEqual(oldV.Field(i).Interface(), newV.Field(i).Interface()) {
+					fields = append(fields, restartField{fpath, oldV.Field(i), newV.Field(i)})
+				}
+
+				continue
+			}
+
+			fields = append(fields, diffRestartFields(oldV.Field(i), newV.Field(i), fpath)...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < oldV.Len() && i < newV.Len(); i++ {
+			fields = append(fields, diffRestartFields(oldV.Index(i), newV.Index(i), indexPath(path, i))...)
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(oldV) {
+			if nv := newV.MapIndex(key); nv.IsValid() {
+				fields = append(fields, diffRestartFields(oldV.MapIndex(key), nv, keyPath(path, key))...)
+			}
+		}
+	}
+
+	return fields
+}
+
+// restartPaths returns the paths of the given fields.
+func restartPaths(fields []restartField) []string {
+	paths := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		paths = append(paths, field.path)
+	}
+
+	return paths
+}
+
+// restoreRestartFields sets back copies of the current values of the given
+// fields in the new configuration. Fields which are not settable (e.g. map
+// values) are left untouched and their paths returned.
+func restoreRestartFields(fields []restartField) []string {
+	var unrestored []string
+
+	for _, field := range fields {
+		if !field.newValue.CanSet() {
+			unrestored = append(unrestored, field.path)
+			continue
+		}
+
+		// The new configuration must not share memory with the current one
+		copied := reflect.New(field.oldValue.Type()).Elem()
+		copyValue(copied, field.oldValue, make(map[copyKey]reflect.Value))
+		field.newValue.Set(copied)
+	}
+
+	return unrestored
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type restartHTTP struct {
+	Port    int    `yaml:"port"    reload:"restart"`
+	Address string `yaml:"address"`
+}
+
+type restartConfig struct {
+	File string      `reload:"restart"`
+	HTTP restartHTTP `yaml:"http"`
+}
+
+func (c *restartConfig) DeepCopyConfig() Config {
+	copy := *c
+	return &copy
+}
+
+func (c *restartConfig) ConfigFile() string {
+	return c.File
+}
+
+func TestRestartFields(t *testing.T) {
+	current := &restartConfig{File: "config.yaml", HTTP: restartHTTP{Port: 8080, Address: "127.0.0.1"}}
+	newConf := &restartConfig{File: "config.yaml", HTTP: restartHTTP{Port: 9090, Address: "0.0.0.0"}}
+
+	fields := restartFields(current, newConf)
+
+	if paths := restartPaths(fields); !reflect.DeepEqual(paths, []string{"http.port"}) {
+		t.Fatalf("unexpected restart fields %v", paths)
+	}
+
+	if err := (&RestartRequiredError{restartPaths(fields)}); err.Error() != "restart required for: http.port" {
+		t.Errorf("unexpected error message `%s`", err)
+	}
+
+	if unrestored := restoreRestartFields(fields); len(unrestored) > 0 {
+		t.Fatalf("fields %v have not been restored", unrestored)
+	}
+
+	if newConf.HTTP.Port != 8080 || newConf.HTTP.Address != "0.0.0.0" {
+		t.Errorf("unexpected config after restore: %#v", newConf)
+	}
+
+	if fields := restartFields(nil, newConf); len(fields) > 0 {
+		t.Errorf("no restart fields expected on initial load, got %v", restartPaths(fields))
+	}
+}
+
+type restartDatabase struct {
+	Host string `yaml:"host" reload:"restart"`
+}
+
+type restartManagerConfig struct {
+	File     string           `short:"f" long:"config"`
+	HTTP     restartHTTP      `yaml:"http"`
+	Tags     []string         `yaml:"tags"     reload:"restart"`
+	Database *restartDatabase `yaml:"database" no-flag:"t"`
+}
+
+func (c *restartManagerConfig) ConfigFile() string {
+	return c.File
+}
+
+// restartRequiredPaths returns the paths of the *RestartRequiredError found in
+// the validation error err.
+func restartRequiredPaths(t *testing.T, err error) []string {
+	t.Helper()
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	for _, err := range verr.Errors {
+		var rerr *RestartRequiredError
+		if errors.As(err, &rerr) {
+			return rerr.Paths
+		}
+	}
+
+	t.Fatalf("expected a *RestartRequiredError, got %v", verr.Errors)
+	return nil
+}
+
+func TestRestartPolicies(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	mustWrite(t, configFile, "http: {port: 8080, address: a}\ntags: [x]\n")
+	setArgs(t, "-f", configFile)
+
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	if err := confManager.MakeConfig(ctx, "reject", &restartManagerConfig{}, WithRestartPolicy(RestartPolicyReject)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := confManager.MakeConfig(ctx, "apply-others", &restartManagerConfig{}, WithRestartPolicy(RestartPolicyApplyOthers)); err != nil {
+		t.Fatal(err)
+	}
+
+	current := confManager.GetConfig("apply-others").(*restartManagerConfig)
+	mustWrite(t, configFile, "http: {port: 9090, address: b}\ntags: [y]\n")
+
+	// Reject
+	paths := restartRequiredPaths(t, confManager.Reload(ctx, "reject"))
+	if expected := []string{"http.port", "tags"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected restart required for %v, got %v", expected, paths)
+	}
+
+	if conf := confManager.GetConfig("reject").(*restartManagerConfig); conf.HTTP.Port != 8080 || conf.HTTP.Address != "a" {
+		t.Errorf("rejected configuration should not have been applied: %+v", conf)
+	}
+
+	if paths := confManager.RestartRequired("reject"); !reflect.DeepEqual(paths, []string{"http.port", "tags"}) {
+		t.Errorf("unexpected restart required paths %v", paths)
+	}
+
+	// Apply others
+	if err := confManager.Reload(ctx, "apply-others"); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := confManager.GetConfig("apply-others").(*restartManagerConfig)
+
+	if conf.HTTP.Port != 8080 || conf.HTTP.Address != "b" || !reflect.DeepEqual(conf.Tags, []string{"x"}) {
+		t.Errorf("expected restart fields to be kept and the others applied: %+v", conf)
+	}
+
+	if &conf.Tags[0] == &current.Tags[0] {
+		t.Errorf("restored fields should not share memory with the current configuration")
+	}
+
+	if paths := confManager.RestartRequired("apply-others"); !reflect.DeepEqual(paths, []string{"http.port", "tags"}) {
+		t.Errorf("unexpected restart required paths %v", paths)
+	}
+
+	// Setting a pointer to a struct holding restart fields
+	mustWrite(t, configFile, "http: {port: 8080, address: a}\ntags: [x]\ndatabase: {host: db}\n")
+
+	paths = restartRequiredPaths(t, confManager.Reload(ctx, "reject"))
+	if expected := []string{"database"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected restart required for %v, got %v", expected, paths)
+	}
+}
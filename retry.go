@@ -0,0 +1,108 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+// ErrFileNotReady is returned when the config file is empty, which usually
+// means that it is being written.
+var ErrFileNotReady = errors.New("config file is not ready")
+
+// RetryPolicy defines how failures to load or parse the config file are
+// retried, e.g. while it is being written. Missing files and invalid command
+// line options fail straight away.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts to load the config file,
+	// including the first one. Values lower than 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts.
+	MaxBackoff time.Duration
+	// Multiplier is the factor applied to the delay after each retry.
+	Multiplier float64
+}
+
+// DefaultRetryPolicy is the retry policy used when none has been given.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+}
+
+// backoff returns the delay to wait before the given retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := float64(p.InitialBackoff)
+
+	for i := 1; i < retry; i++ {
+		if p.Multiplier > 1 {
+			delay *= p.Multiplier
+		}
+
+		if p.MaxBackoff > 0 && delay >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+
+	return time.Duration(delay)
+}
+
+// LoadError is returned when the configuration could not be loaded after all
+// the attempts allowed by the retry policy.
+type LoadError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("error while loading conf, giving up after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// loadConfigWithRetry loads a new configuration, retrying according to the
+// retry policy. newConfig is called to get the config to load into before
+// each attempt.
+func (w *watcher) loadConfigWithRetry(ctx context.Context, newConfig func() Config) (Config, error) {
+	policy := w.retryPolicy
+
+	for attempt := 1; ; attempt++ {
+		conf := newConfig()
+		err := w.loadConfig(conf)
+
+		if err == nil {
+			return conf, nil
+		}
+
+		if attempt >= policy.MaxAttempts || !isTransient(err) {
+			return nil, &LoadError{Attempts: attempt, Err: err}
+		}
+
+		delay := policy.backoff(attempt)
+		w.logger.Warnf("Error while loading conf (attempt %d/%d), retrying in %s: %v", attempt, policy.MaxAttempts, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, &LoadError{Attempts: attempt, Err: err}
+		case <-w.clock.After(delay):
+		}
+	}
+}
+
+// isTransient returns true if loading the config file again may succeed, which
+// is the case of all the errors but missing files and invalid command line
+// options.
+func isTransient(err error) bool {
+	var flagsErr *flags.Error
+
+	return !errors.Is(err, os.ErrNotExist) && !errors.As(err, &flagsErr)
+}
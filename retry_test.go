@@ -0,0 +1,125 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 3}
+
+	for retry, expected := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 300 * time.Millisecond,
+		3: 900 * time.Millisecond,
+		4: time.Second,
+		9: time.Second,
+	} {
+		if backoff := policy.backoff(retry); backoff != expected {
+			t.Errorf("retry %d: expected %s, got %s", retry, expected, backoff)
+		}
+	}
+}
+
+func TestRetryEmptyFile(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	mustWrite(t, configFile, "verbose: [true]\n")
+	setArgs(t, "-f", configFile)
+
+	name := "retry"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: 50 * time.Millisecond}
+
+	// Polling with a long interval prevents reloads from being triggered by the file changes
+	err := confManager.MakeConfig(ctx, name, &MyConfig{}, WithRetry(policy), WithPolling(), WithPollInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty file is not ready and the reload eventually gives up
+	mustWrite(t, configFile, "")
+
+	err = confManager.Reload(ctx, name)
+
+	var lerr *LoadError
+	if !errors.As(err, &lerr) || lerr.Attempts != 3 || !errors.Is(err, ErrFileNotReady) {
+		t.Fatalf("expected a LoadError after 3 attempts, got %v", err)
+	}
+
+	if status, _ := confManager.Status(name); !status.GaveUp || status.ConsecutiveFailures != 1 || status.LastError == nil {
+		t.Errorf("unexpected status %#v", status)
+	}
+
+	// File is written while the reload is being retried
+	time.AfterFunc(10*time.Millisecond, func() {
+		_ = ioutil.WriteFile(configFile, []byte("verbose: [true, true]\n"), 0600)
+	})
+
+	mustWrite(t, configFile, "")
+
+	if err := confManager.Reload(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 2 {
+		t.Errorf("expected verbose to be 2, got %d", v)
+	}
+
+	if status, _ := confManager.Status(name); status.GaveUp || status.LastError != nil || status.LastReason != ReasonAPI {
+		t.Errorf("unexpected status %#v", status)
+	}
+}
+
+func TestRetryPermanentErrors(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	invalidFile := filepath.Join(dir, "invalid.yaml")
+	mustWrite(t, invalidFile, "verbose: [true\n")
+
+	// A long backoff would make the test time out if errors were retried
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}
+
+	for name, args := range map[string][]string{
+		"missing": {"-f", filepath.Join(dir, "missing.yaml")},
+		"options": {"-f", invalidFile, "--no-such-flag"},
+	} {
+		setArgs(t, args...)
+
+		err := confManager.MakeConfig(ctx, name, &MyConfig{}, WithRetry(policy))
+
+		var lerr *LoadError
+		if !errors.As(err, &lerr) || lerr.Attempts != 1 {
+			t.Errorf("%s: expected a LoadError after 1 attempt, got %v", name, err)
+		}
+	}
+
+	// Parse errors are retried as the file may be partially written
+	setArgs(t, "-f", invalidFile)
+	policy.InitialBackoff = time.Millisecond
+
+	err := confManager.MakeConfig(ctx, "invalid", &MyConfig{}, WithRetry(policy))
+
+	var lerr *LoadError
+	if !errors.As(err, &lerr) || lerr.Attempts != 3 {
+		t.Errorf("expected a LoadError after 3 attempts, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"reflect"
+	"runtime/debug"
+)
+
+// SelfValidator can be implemented by a Config or by the type of any of its
+// fields to check its own invariants. The Manager discovers it by reflection
+// and calls it on every reload, prefixing the errors with the path of the
+// field, so shared types (e.g. a TLS or database config) validate themselves
+// wherever they are used.
+//
+// Types which need to compare the new value to the current one can instead
+// declare a `Validate(old T) []error` method, T being the receiver type. old
+// is the value found at the same path in the current configuration, the zero
+// value of T if there is none.
+type SelfValidator interface {
+	Validate() error
+}
+
+var (
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+	errorSliceType = reflect.TypeOf([]error(nil))
+)
+
+// validateMethods calls the Validate methods found in newConfig.
+func validateMethods(currentConfig Config, newConfig Config) []error {
+	if newConfig == nil {
+		return nil
+	}
+
+	var old reflect.Value
+	if currentConfig != nil {
+		old = reflect.ValueOf(currentConfig)
+	}
+
+	return validateMethodsValue(reflect.ValueOf(newConfig), old, "", make(map[validateVisit]bool))
+}
+
+// validateMethodsValue walks v, along with old, the value at the same path in
+// the current configuration, and calls the Validate methods it encounters.
+func validateMethodsValue(v reflect.Value, old reflect.Value, path string, visited map[validateVisit]bool) []error {
+	var errs []error
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		visit := validateVisit{v.Pointer(), v.Type()}
+
+		if v.IsNil() || visited[visit] {
+			return nil
+		}
+
+		visited[visit] = true
+
+		return validateMethodsValue(v.Elem(), elem(old), path, visited)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return validateMethodsValue(v.Elem(), elem(old), path, visited)
+	}
+
+	errs = append(errs, callValidateMethod(v, old, path)...)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			// Skip unexported fields
+			if len(field.PkgPath) > 0 {
+				continue
+			}
+
+			fpath := path
+			if !isInlined(field) {
+				fpath = joinPath(path, fieldName(field))
+			}
+
+			var oldField reflect.Value
+			if old.IsValid() && old.Type() == t {
+				oldField = old.Field(i)
+			}
+
+			errs = append(errs, validateMethodsValue(v.Field(i), oldField, fpath, visited)...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			var oldElem reflect.Value
+			if old.IsValid() && old.Kind() == v.Kind() && i < old.Len() {
+				oldElem = old.Index(i)
+			}
+
+			errs = append(errs, validateMethodsValue(v.Index(i), oldElem, indexPath(path, i), visited)...)
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(v) {
+			var oldElem reflect.Value
+			if old.IsValid() && old.Kind() == reflect.Map && !old.IsNil() {
+				oldElem = old.MapIndex(key)
+			}
+
+			errs = append(errs, validateMethodsValue(v.MapIndex(key), oldElem, keyPath(path, key), visited)...)
+		}
+	}
+
+	return errs
+}
+
+// elem dereferences old if it is a non nil pointer or interface.
+func elem(old reflect.Value) reflect.Value {
+	if old.IsValid() && (old.Kind() == reflect.Ptr || old.Kind() == reflect.Interface) && !old.IsNil() {
+		return old.Elem()
+	}
+
+	return reflect.Value{}
+}
+
+// callValidateMethod calls the Validate method of v, if any, and prefixes its
+// errors with path.
+func callValidateMethod(v reflect.Value, old reflect.Value, path string) (errs []error) {
+	// Methods with pointer receivers are also called on map values, which
+	// are not addressable, through a copy
+	var receiver reflect.Value
+	if v.CanAddr() {
+		receiver = v.Addr()
+	} else {
+		receiver = reflect.New(v.Type())
+		receiver.Elem().Set(v)
+	}
+
+	method := receiver.MethodByName("Validate")
+
+	if !method.IsValid() {
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			errs = append(errs, fieldError(path, &PanicError{Value: r, Stack: debug.Stack()}))
+		}
+	}()
+
+	mt := method.Type()
+
+	switch {
+	case mt.NumIn() == 0 && mt.NumOut() == 1 && mt.Out(0) == errorType:
+		if err, _ := method.Call(nil)[0].Interface().(error); err != nil {
+			errs = append(errs, fieldError(path, err))
+		}
+	case mt.NumIn() == 1 && mt.NumOut() == 1 && mt.Out(0) == errorSliceType && (mt.In(0) == receiver.Type() || mt.In(0) == v.Type()):
+		verrs, _ := method.Call([]reflect.Value{oldArgument(old, mt.In(0))})[0].Interface().([]error)
+
+		for _, err := range verrs {
+			errs = append(errs, fieldError(path, err))
+		}
+	}
+
+	return errs
+}
+
+// oldArgument converts old to the argument type of a Validate(old) method.
+func oldArgument(old reflect.Value, t reflect.Type) reflect.Value {
+	if !old.IsValid() {
+		return reflect.Zero(t)
+	}
+
+	if t.Kind() == reflect.Ptr && old.Type() == t.Elem() {
+		if old.CanAddr() {
+			return old.Addr()
+		}
+
+		// Map values are not addressable
+		p := reflect.New(t.Elem())
+		p.Elem().Set(old)
+
+		return p
+	}
+
+	if old.Type() == t {
+		return old
+	}
+
+	return reflect.Zero(t)
+}
+
+// fieldError prefixes err with the path of the field it relates to.
+func fieldError(path string, err error) error {
+	if len(path) == 0 {
+		return err
+	}
+
+	return &FieldError{Path: path, Rule: "Validate", Err: err}
+}
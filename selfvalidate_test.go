@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+type selfTLS struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+func (t *selfTLS) Validate() error {
+	if len(t.Cert) > 0 && len(t.Key) == 0 {
+		return errors.New("key is required along with cert")
+	}
+
+	return nil
+}
+
+type selfDB struct {
+	Driver string `yaml:"driver"`
+}
+
+func (d selfDB) Validate(old selfDB) []error {
+	if len(old.Driver) > 0 && old.Driver != d.Driver {
+		return []error{errors.New("driver can not be changed")}
+	}
+
+	return nil
+}
+
+type selfConfig struct {
+	TLS       selfTLS            `yaml:"tls"`
+	Upstreams map[string]selfTLS `yaml:"upstreams"`
+	DB        *selfDB            `yaml:"db"`
+}
+
+func (c *selfConfig) ConfigFile() string {
+	return ""
+}
+
+func TestValidateMethods(t *testing.T) {
+	current := &selfConfig{DB: &selfDB{Driver: "mysql"}}
+	conf := &selfConfig{
+		TLS:       selfTLS{Cert: "cert.pem"},
+		Upstreams: map[string]selfTLS{"a": {Cert: "a.pem", Key: "a.key"}, "b": {Cert: "b.pem"}},
+		DB:        &selfDB{Driver: "postgres"},
+	}
+
+	errs := validateMethods(current, conf)
+
+	expected := []string{"tls", "upstreams[b]", "db"}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), errs)
+	}
+
+	for i, err := range errs {
+		var ferr *FieldError
+		if !errors.As(err, &ferr) || ferr.Path != expected[i] {
+			t.Errorf("expected error on %s, got %v", expected[i], err)
+		}
+	}
+
+	// Validate(old) receives the zero value on the first load
+	if errs := validateMethods(nil, &selfConfig{DB: &selfDB{Driver: "mysql"}}); len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+type selfHolder struct {
+	TLS      selfTLS `yaml:"tls"`
+	Upstream selfTLS `yaml:"upstream"`
+}
+
+type selfSharedConfig struct {
+	TLS    *selfTLS    `yaml:"tls"`
+	Holder *selfHolder `yaml:"holder"`
+}
+
+func (c *selfSharedConfig) ConfigFile() string {
+	return ""
+}
+
+func TestValidateMethodsSharedAddress(t *testing.T) {
+	holder := &selfHolder{Upstream: selfTLS{Cert: "cert.pem"}}
+
+	// The holder has the address of its first field which is walked first
+	errs := validateMethods(nil, &selfSharedConfig{TLS: &holder.TLS, Holder: holder})
+
+	var ferr *FieldError
+	if len(errs) != 1 || !errors.As(errs[0], &ferr) || ferr.Path != "holder.upstream" {
+		t.Errorf("expected an error on holder.upstream, got %v", errs)
+	}
+}
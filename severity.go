@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Severity tells whether an issue found by a validator blocks the reload.
+type Severity int
+
+const (
+	// SeverityError blocks the reload. Errors returned by validators have
+	// this severity unless they have been wrapped with Warn or Notice.
+	SeverityError Severity = iota
+	// SeverityWarning does not block the reload unless warnings are treated
+	// as errors, see WithWarningsAsErrors.
+	SeverityWarning
+	// SeverityNotice never blocks the reload.
+	SeverityNotice
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityNotice:
+		return "notice"
+	}
+
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic is an issue found by a validator which does not necessarily
+// block the reload.
+type Diagnostic struct {
+	Severity Severity
+	Err      error
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %v", d.Severity, d.Err)
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+// Warn turns err into a warning, e.g. `pool size unusually large`. Warnings
+// are logged, reported in the status and in reload events, but do not block
+// the reload.
+func Warn(err error) error {
+	return &Diagnostic{Severity: SeverityWarning, Err: err}
+}
+
+// Notice turns err into a notice, e.g. `deprecated key`. Notices are like
+// warnings but are never treated as errors.
+func Notice(err error) error {
+	return &Diagnostic{Severity: SeverityNotice, Err: err}
+}
+
+// SeverityOf returns the severity of err.
+func SeverityOf(err error) Severity {
+	var d *Diagnostic
+
+	if errors.As(err, &d) {
+		return d.Severity
+	}
+
+	return SeverityError
+}
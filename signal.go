@@ -0,0 +1,73 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// AllConfigs can be given to ReloadOnSignal in place of a configuration name
+// to reload all the configurations of the Manager.
+var AllConfigs = allConfigs{}
+
+type allConfigs struct{}
+
+// ReloadOnSignal reloads the named configuration, or all of them if name is
+// AllConfigs, each time one of the given signals is received. It defaults to
+// SIGHUP if no signal is given. Signal reloads are serialized with the ones
+// triggered by file changes. Signals stop being handled when ctx is cancelled
+// or when the Manager is shut down.
+func (m *Manager) ReloadOnSignal(ctx context.Context, name interface{}, sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	m.mu.Lock()
+	done := m.doneChan()
+	m.mu.Unlock()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+
+	go func() {
+		defer signal.Stop(c)
+
+		for {
+			select {
+			case <-ctx.Done():
+				m.logger.Debugf("Signal handling: context closed")
+				return
+			case <-done:
+				m.logger.Debugf("Signal handling: manager closed")
+				return
+			case sig := <-c:
+				m.logger.Infof("Received signal %s", sig)
+
+				for _, n := range m.signalTargets(name) {
+					if err := m.ReloadWithReason(ctx, n, ReasonSignal); err != nil {
+						m.logger.Errorf("%v", err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// signalTargets returns the names of the configurations to reload.
+func (m *Manager) signalTargets(name interface{}) []interface{} {
+	if _, ok := name.(allConfigs); !ok {
+		return []interface{}{name}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]interface{}, 0, len(m.watchers))
+
+	for n := range m.watchers {
+		names = append(names, n)
+	}
+
+	return names
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+// +build !windows
+
+package config
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloadOnSignal(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	for _, name := range []string{"signal1", "signal2"} {
+		if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events1 := confManager.NewReloadChan("signal1")
+	events2 := confManager.NewReloadChan("signal2")
+
+	confManager.ReloadOnSignal(ctx, AllConfigs, syscall.SIGUSR1)
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-events1:
+			if event.Reason != ReasonSignal {
+				t.Errorf("expected reason %s, got %s", ReasonSignal, event.Reason)
+			}
+		case event := <-events2:
+			if event.Reason != ReasonSignal {
+				t.Errorf("expected reason %s, got %s", ReasonSignal, event.Reason)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("No reload event received")
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Stage names the step of a reload during which an error occurred.
+type Stage string
+
+const (
+	// StageValidate is the execution of the validators.
+	StageValidate Stage = "validate"
+	// StageApply is the preparation of the appliers, see TwoPhaseApplier.
+	StageApply Stage = "apply"
+	// StageCommit is the commit of the two-phase appliers.
+	StageCommit Stage = "commit"
+)
+
+// PanicError is returned when a validator or an applier panics.
+type PanicError struct {
+	// Value is the value given to panic.
+	Value interface{}
+	// Stack is the stack trace of the go routine which panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack)
+}
+
+// TimeoutError is returned when a stage of a reload does not complete in time.
+type TimeoutError struct {
+	Stage   Stage
+	Timeout time.Duration
+	// Err is the error returned by the stage when it noticed the deadline of
+	// its context, nil if it did not return in time.
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s stage did not complete within %v", e.Stage, e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// recoverPanic converts a panic into a *PanicError stored in err. It must be
+// deferred.
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
+
+// reloadInfoKey is the context key of the ReloadInfo.
+type reloadInfoKey struct{}
+
+// ReloadInfoFromContext returns the details about the reload carried by the
+// context given to validators and appliers.
+func ReloadInfoFromContext(ctx context.Context) (*ReloadInfo, bool) {
+	info, ok := ctx.Value(reloadInfoKey{}).(*ReloadInfo)
+
+	return info, ok
+}
+
+// runStage runs f with a context carrying info. The context has a deadline if
+// timeout is not 0, in which case a *TimeoutError is returned if f does not
+// return in time. A stage which times out keeps running in the background
+// until it returns, abandoned is then called, if not nil, with its result.
+// returned is closed once f has returned and abandoned has been called.
+func runStage(ctx context.Context, stage Stage, timeout time.Duration, info *ReloadInfo, f func(ctx context.Context) error, abandoned func(err error), returned chan struct{}) error {
+	ctx = context.WithValue(ctx, reloadInfoKey{}, info)
+
+	if timeout <= 0 {
+		defer close(returned)
+		return f(ctx)
+	}
+
+	stageCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// mu makes sure that the result of f is either returned or abandoned
+	var mu sync.Mutex
+	gaveUp := false
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(returned)
+
+		err := f(stageCtx)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !gaveUp {
+			errc <- err
+		} else if abandoned != nil {
+			abandoned(err)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return stageResult(ctx, stageCtx, stage, timeout, err)
+	case <-stageCtx.Done():
+		mu.Lock()
+		defer mu.Unlock()
+
+		select {
+		case err := <-errc:
+			return stageResult(ctx, stageCtx, stage, timeout, err)
+		default:
+			gaveUp = true
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		return &TimeoutError{Stage: stage, Timeout: timeout}
+	}
+}
+
+// stageResult returns the error of a stage which returned. Context aware stages
+// return as soon as their deadline is exceeded, their error is then turned into
+// a *TimeoutError unless ctx, the parent context, is done as well.
+func stageResult(ctx context.Context, stageCtx context.Context, stage Stage, timeout time.Duration, err error) error {
+	if err == nil || stageCtx.Err() != context.DeadlineExceeded || ctx.Err() != nil {
+		return err
+	}
+
+	return &TimeoutError{Stage: stage, Timeout: timeout, Err: err}
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+func TestStagePanics(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "panics"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	confManager.AddValidators(name, func(currentConfig Config, newConfig Config) []error {
+		if len(newConfig.(*MyConfig).Verbose) == 2 {
+			panic("validator panic")
+		}
+
+		return nil
+	})
+
+	confManager.AddAppliers(name, func(currentConfig Config, newConfig Config) error {
+		if len(newConfig.(*MyConfig).Verbose) == 3 {
+			var conf *MyConfig
+			_ = conf.Verbose
+		}
+
+		return nil
+	})
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	setArgs(t, "-vv")
+	err := confManager.Reload(ctx, name)
+
+	var verr *ValidationError
+	var perr *PanicError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 || !errors.As(verr.Errors[0], &perr) {
+		t.Fatalf("expected a validation error caused by a panic, got %v", err)
+	}
+
+	if perr.Value != "validator panic" || !strings.Contains(string(perr.Stack), "TestStagePanics") {
+		t.Errorf("unexpected panic error %v", perr)
+	}
+
+	setArgs(t, "-vvv")
+	err = confManager.Reload(ctx, name)
+
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a panic error, got %v", err)
+	}
+}
+
+func TestStageTimeouts(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "timeouts"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	confManager.AddAppliers(name, func(currentConfig Config, newConfig Config) error {
+		if len(newConfig.(*MyConfig).Verbose) == 2 {
+			<-release
+		}
+
+		return nil
+	})
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}, WithTimeouts(0, 10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	setArgs(t, "-vv")
+	err := confManager.Reload(ctx, name)
+
+	var terr *TimeoutError
+	if !errors.As(err, &terr) || terr.Stage != StageApply {
+		t.Fatalf("expected a timeout of the apply stage, got %v", err)
+	}
+
+	status, _ := confManager.Status(name)
+	if !errors.As(status.LastError, &terr) {
+		t.Errorf("expected the timeout to be reported in the status, got %v", status.LastError)
+	}
+}
+
+func TestContextAware(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "context"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	confManager.AddValidatorsCtx(name, func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) []error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("expected the context of validators to have a deadline")
+		}
+
+		if ctxInfo, ok := ReloadInfoFromContext(ctx); !ok || ctxInfo != info || ctxInfo.Name != name {
+			t.Errorf("expected the context to carry the reload info, got %v", ctxInfo)
+		}
+
+		return nil
+	})
+
+	canceled := make(chan error, 1)
+	confManager.AddApplierCtx(name, func(ctx context.Context, currentConfig Config, newConfig Config, info *ReloadInfo) error {
+		if info.Reason == ReasonInitial {
+			return nil
+		}
+
+		<-ctx.Done()
+		canceled <- ctx.Err()
+
+		return ctx.Err()
+	}, "verbose")
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}, WithTimeouts(time.Second, 10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	setArgs(t, "-vv")
+	err := confManager.Reload(ctx, name)
+
+	var terr *TimeoutError
+	if !errors.As(err, &terr) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	if err := <-canceled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the applier context to exceed its deadline, got %v", err)
+	}
+}
+
+func TestStageResult(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stageCtx, stageCancel := context.WithTimeout(ctx, time.Nanosecond)
+	defer stageCancel()
+	<-stageCtx.Done()
+
+	// Stage returning the error of its context after the deadline
+	var terr *TimeoutError
+	if err := stageResult(ctx, stageCtx, StageApply, time.Nanosecond, stageCtx.Err()); !errors.As(err, &terr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a timeout wrapping the context error, got %v", err)
+	}
+
+	// Stage completing successfully despite the deadline
+	if err := stageResult(ctx, stageCtx, StageApply, time.Nanosecond, nil); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// Stage completing before its deadline
+	liveCtx, liveCancel := context.WithTimeout(ctx, time.Hour)
+	defer liveCancel()
+
+	stageErr := errors.New("failed")
+	if err := stageResult(ctx, liveCtx, StageApply, time.Hour, stageErr); err != stageErr {
+		t.Errorf("expected the stage error, got %v", err)
+	}
+
+	// Parent context canceled
+	cancel()
+	if err := stageResult(ctx, stageCtx, StageApply, time.Nanosecond, stageCtx.Err()); errors.As(err, &terr) {
+		t.Errorf("expected no timeout when the parent context is done, got %v", err)
+	}
+}
+
+func TestTimedOutStageBlocksNextReload(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "abandoned"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	release := make(chan struct{})
+	running := atomic.NewInt32(0)
+	calls := atomic.NewInt32(0)
+
+	confManager.AddAppliers(name, func(currentConfig Config, newConfig Config) error {
+		calls.Inc()
+
+		if running.Inc() > 1 {
+			t.Errorf("appliers of two reloads are running at the same time")
+		}
+		defer running.Dec()
+
+		if len(newConfig.(*MyConfig).Verbose) == 2 {
+			<-release
+		}
+
+		return nil
+	})
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}, WithTimeouts(0, 10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	setArgs(t, "-vv")
+
+	var terr *TimeoutError
+	if err := confManager.Reload(ctx, name); !errors.As(err, &terr) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	// The next reload waits for the abandoned applier
+	setArgs(t, "-vvv")
+	errc := make(chan error, 1)
+	go func() { errc <- confManager.Reload(ctx, name) }()
+
+	select {
+	case err := <-errc:
+		t.Fatalf("reload should wait for the abandoned stage, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if c := calls.Load(); c != 2 {
+		t.Errorf("expected the appliers to have been called twice, got %d", c)
+	}
+
+	close(release)
+
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 3 {
+		t.Errorf("expected verbose to be 3, got %d", v)
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// Status describes the state of a configuration.
+type Status struct {
+	// LastReload is the time of the last reload attempt.
+	LastReload time.Time
+	// LastReason is the reason of the last reload attempt.
+	LastReason Reason
+	// LastSuccess is the time at which a new configuration was last applied.
+	LastSuccess time.Time
+	// LastError is the error of the last reload attempt, nil if it succeeded.
+	LastError error
+	// ConsecutiveFailures counts the failed reloads since the last success.
+	ConsecutiveFailures int
+	// GaveUp is true if the last reload failed to load the config file after
+	// all the attempts allowed by the retry policy.
+	GaveUp bool
+	// Warnings lists the warnings and notices returned by the validators
+	// during the last reload attempt.
+	Warnings []error
+	// CommitErrors lists the errors of the two-phase appliers committed by
+	// the last reload attempt. They do not make the reload fail as the new
+	// configuration has already been applied.
+	CommitErrors []error
+}
+
+// Status returns the status of the named configuration and false if the
+// configuration does not exist.
+func (m *Manager) Status(name interface{}) (Status, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if w, ok := m.watchers[name]; ok {
+		return w.status, true
+	}
+
+	return Status{}, false
+}
+
+// recordStatus updates the status of the named configuration after a reload.
+func (m *Manager) recordStatus(name interface{}, reason Reason, err error, warnings []error, commitErrs []error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.watchers[name]
+
+	if !ok {
+		return
+	}
+
+	now := w.clock.Now()
+
+	w.status.LastReload = now
+	w.status.LastReason = reason
+	w.status.LastError = err
+	w.status.Warnings = warnings
+	w.status.CommitErrors = commitErrs
+
+	if err == nil {
+		w.status.LastSuccess = now
+		w.status.ConsecutiveFailures = 0
+		w.status.GaveUp = false
+		return
+	}
+
+	var lerr *LoadError
+
+	w.status.ConsecutiveFailures++
+	w.status.GaveUp = errors.As(err, &lerr)
+}
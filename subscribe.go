@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ValueChan is a channel within which parts of a new configuration will be sent.
+type ValueChan chan interface{}
+
+// Selector is a function type which extracts a part of a configuration.
+type Selector func(conf Config) interface{}
+
+// subscription is a channel which only receives a part of the configuration
+// when that part has changed.
+type subscription struct {
+	path     string
+	selector Selector
+	c        ValueChan
+}
+
+// Subscribe returns a channel that will receive the value of the field
+// designated by path (e.g. `database` or `database.replicas[0]`) each time
+// the configuration is reloaded and something under that path has changed.
+// It returns an error if the configuration does not exist or if path does not
+// designate a field of its type.
+func (m *Manager) Subscribe(name interface{}, path string) (ValueChan, error) {
+	conf := m.GetConfig(name)
+
+	if conf == nil {
+		return nil, fmt.Errorf("configuration `%v` does not exist", name)
+	}
+
+	if err := checkPath(reflect.TypeOf(conf), path); err != nil {
+		return nil, err
+	}
+
+	c := make(ValueChan)
+	m.registerSubscription(name, &subscription{path: path, c: c})
+
+	return c, nil
+}
+
+// SubscribeFunc returns a channel that will receive the value returned by
+// selector each time the configuration is reloaded and that value differs
+// from the one returned for the previous configuration.
+func (m *Manager) SubscribeFunc(name interface{}, selector Selector) ValueChan {
+	c := make(ValueChan)
+	m.registerSubscription(name, &subscription{selector: selector, c: c})
+
+	return c
+}
+
+func (m *Manager) registerSubscription(name interface{}, s *subscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.subscriptions == nil {
+		m.subscriptions = make(map[interface{}][]*subscription)
+	}
+
+	m.subscriptions[name] = append(m.subscriptions[name], s)
+}
+
+// selection returns the value the subscription is interested in and whether
+// it has changed between currentConfig and newConfig.
+func (s *subscription) selection(currentConfig Config, newConfig Config, changes Changes) (interface{}, bool, error) {
+	if s.selector != nil {
+		value := s.selector(newConfig)
+
+		if currentConfig == nil {
+			return value, true, nil
+		}
+
+		return value, !reflect.DeepEqual(s.selector(currentConfig), value), nil
+	}
+
+	if !changes.Has(s.path) {
+		return nil, false, nil
+	}
+
+	value, err := valueAtPath(newConfig, s.path)
+
+	return value, err == nil, err
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestValueAtPath(t *testing.T) {
+	conf := &diffConfig{
+		File:     "config.yaml",
+		Database: &diffDatabase{Host: "db1", Replicas: []string{"r1", "r2"}},
+		Labels:   map[string]string{"env": "prod"},
+	}
+
+	for path, expected := range map[string]interface{}{
+		"file":                 "config.yaml",
+		"database.host":        "db1",
+		"database.replicas[1]": "r2",
+		"database.replicas[5]": nil,
+		"labels[env]":          "prod",
+		"database":             conf.Database,
+	} {
+		value, err := valueAtPath(conf, path)
+
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(value, expected) {
+			t.Errorf("%s: expected %#v, got %#v", path, expected, value)
+		}
+	}
+
+	for _, path := range []string{"unknown", "file.host", "database.replicas[a]", "labels[env"} {
+		if _, err := valueAtPath(conf, path); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+
+	if value, err := valueAtPath(&diffConfig{}, "database.host"); err != nil || value != nil {
+		t.Errorf("expected nil value through nil pointer, got %#v, %v", value, err)
+	}
+}
+
+func TestSubscriptionSelection(t *testing.T) {
+	current := &diffConfig{File: "config.yaml", Database: &diffDatabase{Host: "db1"}}
+	newConf := &diffConfig{File: "config.yaml", Database: &diffDatabase{Host: "db2"}}
+	changes := diffConfigs(current, newConf)
+
+	database := &subscription{path: "database"}
+	file := &subscription{path: "file"}
+	selector := &subscription{selector: func(conf Config) interface{} {
+		return conf.(*diffConfig).File
+	}}
+
+	if value, changed, err := database.selection(current, newConf, changes); err != nil || !changed || value != newConf.Database {
+		t.Errorf("database subscription should have been notified: %#v, %v, %v", value, changed, err)
+	}
+
+	if _, changed, _ := file.selection(current, newConf, changes); changed {
+		t.Errorf("file subscription should not have been notified")
+	}
+
+	if _, changed, _ := selector.selection(current, newConf, changes); changed {
+		t.Errorf("selector subscription should not have been notified")
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	typ := reflect.TypeOf(&diffConfig{})
+
+	for _, path := range []string{"file", "database", "database.host", "database.replicas[1]", "labels[env]"} {
+		if err := checkPath(typ, path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+
+	for _, path := range []string{"unknown", "file.host", "database.replicas[a]", "labels[env", "file[0]"} {
+		if err := checkPath(typ, path); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+}
+
+func TestSubscribeInvalidPath(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "subscribe"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	if _, err := confManager.Subscribe(name, "verbose"); err == nil {
+		t.Errorf("subscribing to a configuration which does not exist should fail")
+	}
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := confManager.Subscribe(name, "verbosity"); err == nil {
+		t.Errorf("subscribing to an unknown field should fail")
+	}
+
+	if _, err := confManager.Subscribe(name, "verbose[0]"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
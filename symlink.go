@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks is the maximum number of symlinks followed while resolving a path.
+const maxSymlinks = 255
+
+// symlinkChain is the result of the resolution of a path which may go through
+// symlinks, e.g. the `..data` symlinked directory of Kubernetes configmap
+// volumes or the `current` symlink of Capistrano-style deploy directories.
+type symlinkChain struct {
+	// links lists the symlinks encountered while resolving the path, in order.
+	links []string
+	// target is the final path, free of symlinks.
+	target string
+}
+
+// resolveSymlinkChain follows all the symlinks found in any component of path.
+// The path does not have to exist, in which case target is the path of the
+// missing file once all existing symlinks have been resolved.
+func resolveSymlinkChain(fsys FS, path string) (symlinkChain, error) {
+	var chain symlinkChain
+
+	p, err := filepath.Abs(path)
+
+	if err != nil {
+		return chain, err
+	}
+
+resolve:
+	for {
+		vol := filepath.VolumeName(p)
+		parts := strings.Split(p[len(vol):], string(filepath.Separator))
+		current := vol + string(filepath.Separator)
+
+		for i, part := range parts {
+			if len(part) == 0 {
+				continue
+			}
+
+			next := filepath.Join(current, part)
+			info, err := fsys.Lstat(next)
+
+			if os.IsNotExist(err) {
+				chain.target = filepath.Join(append([]string{next}, parts[i+1:]...)...)
+				return chain, nil
+			} else if err != nil {
+				return chain, err
+			}
+
+			if info.Mode()&os.ModeSymlink != 0 {
+				if len(chain.links) >= maxSymlinks {
+					return chain, fmt.Errorf("too many levels of symbolic links in `%s`", path)
+				}
+
+				chain.links = append(chain.links, next)
+
+				dest, err := fsys.Readlink(next)
+
+				if err != nil {
+					return chain, err
+				}
+
+				if !filepath.IsAbs(dest) {
+					dest = filepath.Join(current, dest)
+				}
+
+				p = filepath.Join(append([]string{dest}, parts[i+1:]...)...)
+				continue resolve
+			}
+
+			current = next
+		}
+
+		chain.target = current
+		return chain, nil
+	}
+}
+
+// dirs returns the directories which need to be watched in order to be
+// notified of changes of any hop of the chain.
+func (c symlinkChain) dirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+
+	for _, p := range append(append([]string{}, c.links...), c.target) {
+		dir := filepath.Dir(p)
+
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+// concerns returns true if name is one of the hops of the chain.
+func (c symlinkChain) concerns(name string) bool {
+	if name == c.target {
+		return true
+	}
+
+	for _, link := range c.links {
+		if name == link {
+			return true
+		}
+	}
+
+	return false
+}
+
+// equal returns true if both chains go through the same hops.
+func (c symlinkChain) equal(o symlinkChain) bool {
+	if c.target != o.target || len(c.links) != len(o.links) {
+		return false
+	}
+
+	for i := range c.links {
+		if c.links[i] != o.links[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,122 @@
+//go:build !windows
+// +build !windows
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResolveSymlinkChain(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Kubernetes configmap volume layout
+	mustMkdir(t, filepath.Join(dir, "cm", "..2021_01_01"))
+	mustWrite(t, filepath.Join(dir, "cm", "..2021_01_01", "config.yaml"), "")
+	mustSymlink(t, "..2021_01_01", filepath.Join(dir, "cm", "..data"))
+	mustSymlink(t, "..data/config.yaml", filepath.Join(dir, "cm", "config.yaml"))
+
+	chain, err := resolveSymlinkChain(osFS{}, filepath.Join(dir, "cm", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := symlinkChain{
+		links: []string{
+			filepath.Join(dir, "cm", "config.yaml"),
+			filepath.Join(dir, "cm", "..data"),
+		},
+		target: filepath.Join(dir, "cm", "..2021_01_01", "config.yaml"),
+	}
+
+	if !reflect.DeepEqual(chain, expected) {
+		t.Errorf("unexpected chain %#v, expected %#v", chain, expected)
+	}
+
+	if dirs := chain.dirs(); !reflect.DeepEqual(dirs, []string{filepath.Join(dir, "cm"), filepath.Join(dir, "cm", "..2021_01_01")}) {
+		t.Errorf("unexpected dirs %v", dirs)
+	}
+
+	// Missing file
+	chain, err = resolveSymlinkChain(osFS{}, filepath.Join(dir, "cm", "..data", "missing", "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if chain.target != filepath.Join(dir, "cm", "..2021_01_01", "missing", "config.yaml") {
+		t.Errorf("unexpected target %s", chain.target)
+	}
+
+	// Loop
+	mustSymlink(t, "loop2", filepath.Join(dir, "loop1"))
+	mustSymlink(t, "loop1", filepath.Join(dir, "loop2"))
+
+	if _, err := resolveSymlinkChain(osFS{}, filepath.Join(dir, "loop1")); err == nil {
+		t.Errorf("expected an error on symlink loop")
+	}
+}
+
+func TestWatchSymlinkSwap(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Capistrano-style deploy directory
+	mustMkdir(t, filepath.Join(dir, "releases", "1"))
+	mustMkdir(t, filepath.Join(dir, "releases", "2"))
+	mustWrite(t, filepath.Join(dir, "releases", "1", "config.yaml"), "verbose: [true]\n")
+	mustWrite(t, filepath.Join(dir, "releases", "2", "config.yaml"), "verbose: [true, true]\n")
+	mustSymlink(t, "releases/1", filepath.Join(dir, "current"))
+
+	configFile := filepath.Join(dir, "current", "config.yaml")
+	setArgs(t, "-f", configFile)
+
+	name := "symlink"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	events := confManager.NewReloadChan(name)
+
+	// Atomically swap the current symlink
+	mustSymlink(t, "releases/2", filepath.Join(dir, "current.tmp"))
+
+	if err := os.Rename(filepath.Join(dir, "current.tmp"), filepath.Join(dir, "current")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		if v := len(event.Config.(*MyConfig).Verbose); v != 2 {
+			t.Errorf("expected verbose to be 2, got %d", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No reload event received")
+	}
+}
+
+func mustSymlink(t *testing.T, oldname string, newname string) {
+	t.Helper()
+
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Fatal(err)
+	}
+}
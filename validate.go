@@ -0,0 +1,374 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// FieldError is the error returned when the value of a configuration field
+// does not comply with the rules declared in its `validate` struct tag.
+type FieldError struct {
+	// Path is the full path of the field, e.g. `database.replicas[2].port`.
+	Path string
+	// Rule is the rule which has not been satisfied.
+	Rule string
+	// Err describes why the rule has not been satisfied.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// tagRule checks that a value complies with a rule, arg being whatever is on
+// the right hand side of the `=` sign in the rule definition.
+type tagRule func(v reflect.Value, arg string) error
+
+// tagRules lists the rules which can be used in `validate` struct tags:
+//
+//	required   the field must not be empty
+//	min=N      numbers must be >= N, strings, slices and maps must have N elements or more
+//	max=N      numbers must be <= N, strings, slices and maps must have N elements or less
+//	oneof=a b  the field must be one of the space separated values
+//	url        the field must be an absolute URL
+//	cidr       the field must be a CIDR notation IP address and prefix length
+//	regexp     the field must be a valid regular expression
+//
+// min and max accept durations (e.g. `min=1s`) on time.Duration fields.
+var tagRules = map[string]tagRule{
+	"required": ruleRequired,
+	"min":      ruleMin,
+	"max":      ruleMax,
+	"oneof":    ruleOneOf,
+	"url":      ruleURL,
+	"cidr":     ruleCIDR,
+	"regexp":   ruleRegexp,
+}
+
+// elementRules lists the rules which apply to each element of slices and
+// arrays rather than to the slice or array itself.
+var elementRules = map[string]bool{
+	"oneof":  true,
+	"url":    true,
+	"cidr":   true,
+	"regexp": true,
+}
+
+// validateTags checks the fields of the given configuration against the rules
+// declared in their `validate` struct tags, or calls ValidateTags if conf is a
+// TagValidator.
+func validateTags(conf Config) []error {
+	if conf == nil {
+		return nil
+	}
+
+	if v, ok := conf.(TagValidator); ok {
+		return v.ValidateTags()
+	}
+
+	return validateValue(reflect.ValueOf(conf), "", make(map[validateVisit]bool))
+}
+
+// validateVisit identifies a pointer which has already been walked. A struct
+// and its first field have the same address so the type is part of it.
+type validateVisit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// validateValue walks v and checks all the struct fields it encounters.
+// Pointers already in visited are not walked again so that cycles terminate.
+func validateValue(v reflect.Value, path string, visited map[validateVisit]bool) []error {
+	var errs []error
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if visit := (validateVisit{v.Pointer(), v.Type()}); !v.IsNil() && !visited[visit] {
+			visited[visit] = true
+			errs = append(errs, validateValue(v.Elem(), path, visited)...)
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			errs = append(errs, validateValue(v.Elem(), path, visited)...)
+		}
+	case reflect.Struct:
+		t := v.Type()
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			// Skip unexported fields
+			if len(field.PkgPath) > 0 {
+				continue
+			}
+
+			fpath := path
+			if !isInlined(field) {
+				fpath = joinPath(path, fieldName(field))
+			}
+
+			if tag := field.Tag.Get("validate"); len(tag) > 0 && tag != "-" {
+				errs = append(errs, validateField(v.Field(i), fpath, tag)...)
+			}
+
+			errs = append(errs, validateValue(v.Field(i), fpath, visited)...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, validateValue(v.Index(i), indexPath(path, i), visited)...)
+		}
+	case reflect.Map:
+		for _, key := range sortedMapKeys(v) {
+			errs = append(errs, validateValue(v.MapIndex(key), keyPath(path, key), visited)...)
+		}
+	}
+
+	return errs
+}
+
+// validateField checks a single field against the rules of its `validate` tag.
+func validateField(v reflect.Value, path string, tag string) []error {
+	var errs []error
+
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+
+		if len(rule) == 0 {
+			continue
+		}
+
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		check, ok := tagRules[name]
+
+		if !ok {
+			errs = append(errs, &FieldError{path, name, fmt.Errorf("unknown validation rule `%s`", name)})
+			continue
+		}
+
+		fv := indirect(v)
+
+		if name != "required" && !fv.IsValid() {
+			// Nil pointers are only checked by the required rule
+			continue
+		}
+
+		if elementRules[name] && (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) {
+			for i := 0; i < fv.Len(); i++ {
+				if err := check(indirect(fv.Index(i)), arg); err != nil {
+					errs = append(errs, &FieldError{indexPath(path, i), name, err})
+				}
+			}
+
+			continue
+		}
+
+		if err := check(fv, arg); err != nil {
+			errs = append(errs, &FieldError{path, name, err})
+		}
+	}
+
+	return errs
+}
+
+// indirect dereferences pointers and interfaces until it reaches a concrete
+// value. It returns the zero Value if it encounters a nil pointer.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+
+		v = v.Elem()
+	}
+
+	return v
+}
+
+func ruleRequired(v reflect.Value, arg string) error {
+	if !v.IsValid() {
+		return fmt.Errorf("is required")
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return fmt.Errorf("is required")
+		}
+	default:
+		if v.IsZero() {
+			return fmt.Errorf("is required")
+		}
+	}
+
+	return nil
+}
+
+func ruleMin(v reflect.Value, arg string) error {
+	return checkBound(v, arg, "min", func(cmp int) bool { return cmp >= 0 }, "greater than or equal to")
+}
+
+func ruleMax(v reflect.Value, arg string) error {
+	return checkBound(v, arg, "max", func(cmp int) bool { return cmp <= 0 }, "less than or equal to")
+}
+
+// checkBound compares the value (or its length) to arg and returns an error if
+// ok() returns false for the result of the comparison.
+func checkBound(v reflect.Value, arg string, rule string, ok func(cmp int) bool, desc string) error {
+	var cmp int
+
+	switch {
+	case v.Type() == durationType:
+		bound, err := time.ParseDuration(arg)
+
+		if err != nil {
+			return fmt.Errorf("invalid argument `%s` for rule `%s`: %v", arg, rule, err)
+		}
+
+		cmp = compareInt(v.Int(), int64(bound))
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		bound, err := strconv.ParseInt(arg, 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid argument `%s` for rule `%s`: %v", arg, rule, err)
+		}
+
+		cmp = compareInt(v.Int(), bound)
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uintptr:
+		bound, err := strconv.ParseUint(arg, 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid argument `%s` for rule `%s`: %v", arg, rule, err)
+		}
+
+		switch {
+		case v.Uint() < bound:
+			cmp = -1
+		case v.Uint() > bound:
+			cmp = 1
+		}
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		bound, err := strconv.ParseFloat(arg, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid argument `%s` for rule `%s`: %v", arg, rule, err)
+		}
+
+		switch {
+		case v.Float() < bound:
+			cmp = -1
+		case v.Float() > bound:
+			cmp = 1
+		}
+	case v.Kind() == reflect.String || v.Kind() == reflect.Slice || v.Kind() == reflect.Map || v.Kind() == reflect.Array:
+		bound, err := strconv.ParseInt(arg, 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid argument `%s` for rule `%s`: %v", arg, rule, err)
+		}
+
+		if !ok(compareInt(int64(v.Len()), bound)) {
+			return fmt.Errorf("length must be %s %s", desc, arg)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("rule `%s` can not be applied to %s", rule, v.Type())
+	}
+
+	if !ok(cmp) {
+		return fmt.Errorf("must be %s %s", desc, arg)
+	}
+
+	return nil
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+func ruleOneOf(v reflect.Value, arg string) error {
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return fmt.Errorf("rule `oneof` can not be applied to %s", v.Type())
+	}
+
+	allowed := strings.Fields(arg)
+	value := fmt.Sprint(v.Interface())
+
+	for _, a := range allowed {
+		if a == value {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("`%s` must be one of [%s]", value, strings.Join(allowed, " "))
+}
+
+// stringRule wraps a check on a string value. Empty strings are not checked,
+// use the required rule to forbid them.
+func stringRule(rule string, check func(s string) error) tagRule {
+	return func(v reflect.Value, arg string) error {
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("rule `%s` can not be applied to %s", rule, v.Type())
+		}
+
+		if v.Len() == 0 {
+			return nil
+		}
+
+		return check(v.String())
+	}
+}
+
+var ruleURL = stringRule("url", func(s string) error {
+	u, err := url.Parse(s)
+
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return fmt.Errorf("`%s` is not a valid URL", s)
+	}
+
+	return nil
+})
+
+var ruleCIDR = stringRule("cidr", func(s string) error {
+	if _, _, err := net.ParseCIDR(s); err != nil {
+		return fmt.Errorf("`%s` is not a valid CIDR", s)
+	}
+
+	return nil
+})
+
+var ruleRegexp = stringRule("regexp", func(s string) error {
+	if _, err := regexp.Compile(s); err != nil {
+		return fmt.Errorf("`%s` is not a valid regular expression: %v", s, err)
+	}
+
+	return nil
+})
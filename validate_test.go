@@ -0,0 +1,196 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+type validateReplica struct {
+	Host string `yaml:"host" validate:"required"`
+	Port int    `yaml:"port" validate:"min=1,max=65535"`
+}
+
+type validateDatabase struct {
+	Driver   string            `yaml:"driver"   validate:"required,oneof=mysql postgres"`
+	URL      string            `yaml:"url"      validate:"url"`
+	Replicas []validateReplica `yaml:"replicas" validate:"max=3"`
+}
+
+type validateConfig struct {
+	File     string            `validate:"required"`
+	Database *validateDatabase `yaml:"database"`
+	Networks []string          `yaml:"networks" validate:"cidr"`
+	Patterns map[string]string `yaml:"patterns"`
+	Timeout  time.Duration     `yaml:"timeout"  validate:"min=1s,max=1m"`
+	Matcher  string            `yaml:"matcher"  validate:"regexp"`
+}
+
+func (c *validateConfig) DeepCopyConfig() Config {
+	copy := *c
+	return &copy
+}
+
+func (c *validateConfig) ConfigFile() string {
+	return c.File
+}
+
+func TestValidateTags(t *testing.T) {
+	valid := func() *validateConfig {
+		return &validateConfig{
+			File: "config.yaml",
+			Database: &validateDatabase{
+				Driver: "mysql",
+				URL:    "tcp://db.local:3306",
+				Replicas: []validateReplica{
+					{Host: "replica1", Port: 3306},
+					{Host: "replica2", Port: 3306},
+				},
+			},
+			Networks: []string{"10.0.0.0/8"},
+			Timeout:  10 * time.Second,
+			Matcher:  "^foo.*$",
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(c *validateConfig)
+		paths  []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(c *validateConfig) {},
+		},
+		{
+			name:   "required",
+			mutate: func(c *validateConfig) { c.File = "" },
+			paths:  []string{"file"},
+		},
+		{
+			name:   "nested slice",
+			mutate: func(c *validateConfig) { c.Database.Replicas[1].Port = 0 },
+			paths:  []string{"database.replicas[1].port"},
+		},
+		{
+			name: "max length",
+			mutate: func(c *validateConfig) {
+				c.Database.Replicas = append(c.Database.Replicas, validateReplica{"r3", 1}, validateReplica{"r4", 70000})
+			},
+			paths: []string{"database.replicas", "database.replicas[3].port"},
+		},
+		{
+			name:   "oneof",
+			mutate: func(c *validateConfig) { c.Database.Driver = "sqlite" },
+			paths:  []string{"database.driver"},
+		},
+		{
+			name:   "url",
+			mutate: func(c *validateConfig) { c.Database.URL = "db.local" },
+			paths:  []string{"database.url"},
+		},
+		{
+			name:   "cidr",
+			mutate: func(c *validateConfig) { c.Networks = append(c.Networks, "10.0.0.1") },
+			paths:  []string{"networks[1]"},
+		},
+		{
+			name:   "duration",
+			mutate: func(c *validateConfig) { c.Timeout = time.Hour },
+			paths:  []string{"timeout"},
+		},
+		{
+			name:   "regexp",
+			mutate: func(c *validateConfig) { c.Matcher = "(foo" },
+			paths:  []string{"matcher"},
+		},
+		{
+			name:   "nil pointer",
+			mutate: func(c *validateConfig) { c.Database = nil },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := valid()
+			test.mutate(conf)
+
+			errs := validateTags(conf)
+
+			if len(errs) != len(test.paths) {
+				t.Fatalf("expected %d errors, got %d: %v", len(test.paths), len(errs), errs)
+			}
+
+			for i, err := range errs {
+				ferr, ok := err.(*FieldError)
+
+				if !ok {
+					t.Fatalf("expected *FieldError, got %T", err)
+				}
+
+				if ferr.Path != test.paths[i] {
+					t.Errorf("expected path `%s`, got `%s` (%v)", test.paths[i], ferr.Path, ferr)
+				}
+			}
+		})
+	}
+}
+
+type validateNode struct {
+	Name string        `yaml:"name" validate:"required"`
+	Next *validateNode `yaml:"next"`
+}
+
+type validateCycleConfig struct {
+	File string        `long:"config"`
+	Head *validateNode `yaml:"head"`
+}
+
+func (c *validateCycleConfig) ConfigFile() string {
+	return c.File
+}
+
+type validateHolder struct {
+	Replica validateReplica `yaml:"replica"`
+	Name    string          `yaml:"name" validate:"required"`
+}
+
+type validateSharedConfig struct {
+	File    string           `long:"config"`
+	Replica *validateReplica `yaml:"replica"`
+	Holder  *validateHolder  `yaml:"holder"`
+}
+
+func (c *validateSharedConfig) ConfigFile() string {
+	return c.File
+}
+
+func TestValidateTagsSharedAddress(t *testing.T) {
+	holder := &validateHolder{Replica: validateReplica{Host: "db", Port: 3306}}
+
+	// The holder has the address of its first field which is walked first
+	errs := validateTags(&validateSharedConfig{Replica: &holder.Replica, Holder: holder})
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+
+	if ferr, ok := errs[0].(*FieldError); !ok || ferr.Path != "holder.name" {
+		t.Errorf("expected an error on `holder.name`, got %v", errs[0])
+	}
+}
+
+func TestValidateTagsCycle(t *testing.T) {
+	a := &validateNode{Name: "a"}
+	b := &validateNode{Next: a}
+	a.Next = b
+
+	errs := validateTags(&validateCycleConfig{Head: a})
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+
+	if ferr, ok := errs[0].(*FieldError); !ok || ferr.Path != "head.next.name" {
+		t.Errorf("expected an error on `head.next.name`, got %v", errs[0])
+	}
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Deriver is a function type which computes a value derived from a
+// configuration, e.g. compiled regular expressions or routing tables.
+type Deriver func(conf Config) (interface{}, error)
+
+// View holds a value derived from a configuration. The value is computed once
+// per successful reload and cached. Derivation errors make the reload fail the
+// same way validation errors do.
+type View struct {
+	derive Deriver
+	value  interface{}
+	chans  []ValueChan
+	mu     sync.RWMutex
+}
+
+// viewValue is a derived value waiting for the reload to be applied before
+// being stored in its view.
+type viewValue struct {
+	view  *View
+	value interface{}
+}
+
+// AddView registers a view of the named configuration. If the configuration
+// already exists the value of the view is derived straight away and the
+// derivation error, if any, is returned. derive is called without holding the
+// Manager's lock so it can use the Manager.
+func (m *Manager) AddView(name interface{}, derive Deriver) (*View, error) {
+	view := &View{derive: derive}
+
+	for {
+		conf := m.GetConfig(name)
+		view.value = nil
+
+		if conf != nil {
+			value, err := callDeriver(derive, conf)
+
+			if err != nil {
+				return nil, err
+			}
+
+			view.value = value
+		}
+
+		m.mu.Lock()
+
+		// Derive again if the configuration has been reloaded meanwhile
+		if w, ok := m.watchers[name]; ok && w.config != conf {
+			m.mu.Unlock()
+			continue
+		}
+
+		if m.views == nil {
+			m.views = make(map[interface{}][]*View)
+		}
+
+		m.views[name] = append(m.views[name], view)
+		m.mu.Unlock()
+
+		return view, nil
+	}
+}
+
+// callDeriver calls derive and turns its panics into errors.
+func callDeriver(derive Deriver, conf Config) (value interface{}, err error) {
+	defer recoverPanic(&err)
+
+	return derive(conf)
+}
+
+// Value returns the value derived from the current configuration.
+func (v *View) Value() interface{} {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.value
+}
+
+// Subscribe returns a channel that will receive the derived value each time it
+// changes after a reload.
+func (v *View) Subscribe() ValueChan {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	c := make(ValueChan)
+	v.chans = append(v.chans, c)
+
+	return c
+}
+
+// set stores the new derived value and notifies the subscribers if it differs
+// from the previous one. Notifications are given up once stopped is closed.
+func (v *View) set(value interface{}, stopped <-chan struct{}) {
+	v.mu.Lock()
+	changed := !reflect.DeepEqual(v.value, value)
+	v.value = value
+	chans := v.chans
+	v.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	for _, c := range chans {
+		select {
+		case c <- value:
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// closeChans closes the channels returned by Subscribe.
+func (v *View) closeChans() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, c := range v.chans {
+		close(c)
+	}
+
+	v.chans = nil
+}
+
+// deriveViews computes the values of the views of the named configuration.
+func (m *Manager) deriveViews(name interface{}, newConfig Config) ([]viewValue, []error) {
+	var values []viewValue
+	var errs []error
+
+	m.mu.RLock()
+	views := m.views[name]
+	m.mu.RUnlock()
+
+	for _, view := range views {
+		value, err := callDeriver(view.derive, newConfig)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("deriving view: %w", err))
+			continue
+		}
+
+		values = append(values, viewValue{view, value})
+	}
+
+	return values, errs
+}
+
+// commitViews stores the derived values in their views.
+func commitViews(values []viewValue, stopped <-chan struct{}) {
+	for _, v := range values {
+		v.view.set(v.value, stopped)
+	}
+}
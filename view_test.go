@@ -0,0 +1,149 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type viewLevel struct {
+	Level int
+}
+
+type viewError struct {
+	verbose int
+}
+
+func (e *viewError) Error() string {
+	return fmt.Sprintf("verbose %d is too high", e.verbose)
+}
+
+func TestViews(t *testing.T) {
+	testWg.Add(1)
+	defer testWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setArgs(t, "-v")
+
+	name := "views"
+	confManager := NewManager(WithLogger(newQuietLogger(t)))
+	defer confManager.Close()
+
+	if err := confManager.MakeConfig(ctx, name, &MyConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	view, err := confManager.AddView(name, func(conf Config) (interface{}, error) {
+		// Derivers can use the Manager
+		if confManager.GetConfig(name) == nil {
+			return nil, errors.New("configuration not found")
+		}
+
+		switch verbose := len(conf.(*MyConfig).Verbose); {
+		case verbose == 4:
+			return nil, &viewError{verbose}
+		case verbose == 5:
+			panic("way too verbose")
+		case verbose > 2:
+			return viewLevel{2}, nil
+		default:
+			return viewLevel{verbose}, nil
+		}
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Initial derive
+	if value := view.Value(); value != (viewLevel{1}) {
+		t.Errorf("expected initial value %v, got %v", viewLevel{1}, value)
+	}
+
+	values := view.Subscribe()
+	received := make(chan interface{}, 10)
+	go func() {
+		for value := range values {
+			received <- value
+		}
+	}()
+
+	expect := func(expected viewLevel) {
+		t.Helper()
+
+		select {
+		case value := <-received:
+			if value != expected {
+				t.Errorf("expected value %v, got %v", expected, value)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no value received")
+		}
+
+		if value := view.Value(); value != expected {
+			t.Errorf("expected value %v, got %v", expected, value)
+		}
+	}
+
+	// Derive on reload
+	setArgs(t, "-vv")
+	if err := confManager.Reload(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	expect(viewLevel{2})
+
+	// Unchanged values are not notified, the next value received is the one
+	// of the following reload
+	setArgs(t, "-vvv")
+	if err := confManager.Reload(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	setArgs(t, "-v")
+	if err := confManager.Reload(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	expect(viewLevel{1})
+
+	// Derivation errors and panics reject the reload
+	setArgs(t, "-vvvv")
+	var verr *viewError
+	assertValidationError(t, confManager.Reload(ctx, name), &verr)
+
+	setArgs(t, "-vvvvv")
+	var perr *PanicError
+	assertValidationError(t, confManager.Reload(ctx, name), &perr)
+
+	if v := len(confManager.GetConfig(name).(*MyConfig).Verbose); v != 1 {
+		t.Errorf("rejected configurations should not have been applied, verbose is %d", v)
+	}
+
+	if value := view.Value(); value != (viewLevel{1}) {
+		t.Errorf("expected value %v, got %v", viewLevel{1}, value)
+	}
+}
+
+// assertValidationError checks that err is a *ValidationError holding an error
+// matching target.
+func assertValidationError(t *testing.T, err error, target interface{}) {
+	t.Helper()
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	for _, err := range verr.Errors {
+		if errors.As(err, target) {
+			return
+		}
+	}
+
+	t.Errorf("expected an error matching %T, got %v", target, verr.Errors)
+}
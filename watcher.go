@@ -1,118 +1,173 @@
 package config
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/sha256"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
-	"path/filepath"
+	"sync"
+	"time"
 
-	toml "github.com/BurntSushi/toml"
 	fsnotify "github.com/fsnotify/fsnotify"
 	flags "github.com/jessevdk/go-flags"
-	"github.com/tailscale/hujson"
-	yaml "sylr.dev/yaml/v3"
 )
 
 type watcher struct {
-	*fsnotify.Watcher
+	// fsWatcher is nil when the config file is polled
+	fsWatcher *fsnotify.Watcher
 
 	manager *Manager
 	logger  Logger
 	name    interface{}
 	config  Config
-}
 
-// reload configuration
-func (w *watcher) reload() {
-	newConfig := w.config.DeepCopyConfig()
+	// initialConfig is the config given to MakeConfig
+	initialConfig Config
+	// baseConfig is a copy of initialConfig before it has been loaded
+	baseConfig Config
+	// pending is the reload waiting to be run by the reload executor, which
+	// is woken up by trigger, and queueErr is set once the executor returns
+	queueMu      sync.Mutex
+	pending      *reloadRequest
+	queueErr     error
+	trigger      chan struct{}
+	executorDone chan struct{}
+	// lastStage is closed once the last stage run by the executor has
+	// returned, see runStage
+	lastStage chan struct{}
+	// stopped is closed when the watcher is stopped
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// cancel stops the watching go routine which closes done when it returns
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	restartPolicy   RestartPolicy
+	restartRequired []string
+
+	polling      bool
+	pollInterval time.Duration
+
+	// optional tells if the config file can be missing
+	optional bool
+	// fileRead tells if the config file has been read by the last load
+	fileRead bool
+	// fileLoaded is closed once a config loaded from file has been applied
+	fileLoaded     chan struct{}
+	fileLoadedOnce sync.Once
+	// loaded is the state of the config file read by the last load
+	loadedMu sync.Mutex
+	loaded   fileState
+
+	retryPolicy RetryPolicy
+	status      Status
+
+	validateTimeout time.Duration
+	applyTimeout    time.Duration
+
+	parallelAppliers     bool
+	validatorConcurrency int
+	warningsAsErrors     bool
+
+	fs            FS
+	clock         Clock
+	parsers       map[string]Parser
+	defaultParser Parser
+}
 
-	// Load config from cli args and then from config file if exists
-	err := w.loadConfig(newConfig)
+// checkRestartFields looks for changes of fields tagged with `reload:"restart"`
+// and enforces the restart policy. It returns an error if the reload has to be
+// rejected.
+func (w *watcher) checkRestartFields(newConfig Config) error {
+	fields := restartFields(w.config, newConfig)
+	w.manager.setRestartRequired(w.name, restartPaths(fields))
 
-	if err != nil {
-		w.logger.Errorf("Error while loading conf: %v", err)
-		return
+	if len(fields) == 0 {
+		return nil
 	}
 
-	// Execute validators
-	errs := w.manager.runValidators(w.name, w.config, newConfig)
+	err := &RestartRequiredError{Paths: restartPaths(fields)}
 
-	if len(errs) > 0 {
-		for _, err := range errs {
-			w.logger.Errorf("Error while validating new conf: %v", err)
+	switch w.restartPolicy {
+	case RestartPolicyApplyOthers:
+		// Fields which can not be restored prevent the reload
+		if unrestored := restoreRestartFields(fields); len(unrestored) > 0 {
+			return &RestartRequiredError{Paths: unrestored}
 		}
 
-		err = fmt.Errorf("New configuration not applied because error(s) have been found")
-		w.logger.Errorf("%v", err)
-		return
-	}
-
-	// Execute appliers
-	err = w.manager.runAppliers(w.name, w.config, newConfig)
-
-	if err != nil {
-		w.logger.Errorf("Error while applying new conf: %v", err)
+		w.logger.Warnf("%v, keeping current values", err)
+		return nil
+	default:
+		return err
 	}
-
-	// update current configuration
-	w.config = newConfig
-	w.manager.broadcastNewConfig(w.name)
 }
 
 func (w *watcher) loadConfig(conf Config) error {
-	// Read cli arguments and loads in into config, it will exit if errors occurs
-	w.readConfigCLIOptions(conf)
-
-	// Read config file content and loads in into config
-	err := w.readConfigFile(conf)
-
-	if err != nil {
-		w.logger.Errorf("Configuration not applied because parsing of config file failed: %s", err)
+	// Read cli arguments and loads in into config
+	if err := w.readConfigCLIOptions(conf); err != nil {
 		return err
 	}
 
-	return nil
+	// Read config file content and loads in into config
+	return w.readConfigFile(conf)
 }
 
-func (w *watcher) watchConfigFile(ctx context.Context) {
-	configFile := w.config.ConfigFile()
+// startWatching watches the directories of all the symlinks leading to the
+// config file, or takes the state of the file to poll, and returns the function
+// looking for changes. It is called before MakeConfig returns so that changes
+// made once it has returned are not missed.
+func (w *watcher) startWatching() func(ctx context.Context) {
+	if w.fsWatcher != nil {
+		configFile := w.initialConfig.ConfigFile()
 
-	w.logger.Debugf("Watching config file `%s`", configFile)
+		w.logger.Debugf("Watching config file `%s`", configFile)
 
-	err := w.Add(configFile)
+		chain, err := resolveSymlinkChain(w.fs, configFile)
 
-	if err != nil {
-		w.logger.Fatalf("%x", err)
+		if err == nil {
+			err = w.watchDirs(nil, chain.dirs())
+		}
+
+		if err == nil {
+			return func(ctx context.Context) {
+				w.watchConfigFile(ctx, chain)
+			}
+		}
+
+		w.logger.Warnf("fsnotify: %v, falling back to polling", err)
+		w.fsWatcher.Close()
+		w.fsWatcher = nil
 	}
 
-	if len(os.Getenv("KUBERNETES_PORT")) > 0 {
-		dir := filepath.Dir(configFile)
-		w.logger.Infof("In kubernetes context, adding `%s` to the watch list", dir)
-		err := w.Add(dir)
+	last := w.startPolling()
 
-		if err != nil {
-			w.logger.Fatalf("%x", err)
-		}
+	return func(ctx context.Context) {
+		w.pollConfigFile(ctx, last)
 	}
+}
+
+// watchConfigFile reloads the configuration when the config file changes until
+// ctx is done. chain is the symlink chain watched by startWatching.
+func (w *watcher) watchConfigFile(ctx context.Context, chain symlinkChain) {
+	defer w.fsWatcher.Close()
 
-	defer w.Close()
+	configFile := w.initialConfig.ConfigFile()
 
 	for {
 		select {
 		case <-ctx.Done():
 			w.logger.Debugf("fsnotify: context closed")
 			return
-		case err, ok := <-w.Errors:
+		case err, ok := <-w.fsWatcher.Errors:
 			if !ok {
 				w.logger.Errorf("fsnotify: watcher.Errors channel has been closed")
 				return
 			}
 
 			w.logger.Errorf("fsnotify: %s", err)
-		case event, ok := <-w.Events:
+		case event, ok := <-w.fsWatcher.Events:
 			if !ok {
 				w.logger.Errorf("fsnotify: watcher.Events channel has been closed")
 				return
@@ -120,49 +175,103 @@ func (w *watcher) watchConfigFile(ctx context.Context) {
 
 			w.logger.Tracef("fsnotify: %s -> %s", event.Name, event.Op.String())
 
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				if event.Name == configFile {
-					w.logger.Debugf("Config file changed")
-				}
-			} else if event.Op&fsnotify.Create == fsnotify.Create {
-				if event.Name == configFile {
-					w.logger.Debugf("Config file created")
-				} else if filepath.Base(event.Name) == "..data" {
-					w.logger.Debugf("Configmap volume updated")
-				} else {
-					break
-				}
-			} else if event.Op&fsnotify.Remove == fsnotify.Remove {
-				w.logger.Debugf("Config file removed")
+			// Ignore events about other files of the watched directories
+			if !chain.concerns(event.Name) {
+				break
+			}
+
+			// Symlinks may have been swapped, renamed or removed
+			newChain, err := resolveSymlinkChain(w.fs, configFile)
+
+			if err != nil {
+				w.logger.Errorf("Error while resolving config file path: %v", err)
+				break
+			}
 
-				err = w.Add(event.Name)
-				if err != nil {
+			if !newChain.equal(chain) {
+				w.logger.Debugf("Config file now resolves to `%s`", newChain.target)
+
+				if err := w.watchDirs(chain.dirs(), newChain.dirs()); err != nil {
 					w.logger.Errorf("fsnotify: %s", err)
 				}
+
+				chain = newChain
+			} else if event.Name != chain.target {
 				break
+			} else if event.Op&fsnotify.Write == fsnotify.Write {
+				w.logger.Debugf("Config file changed")
+			} else if event.Op&fsnotify.Create == fsnotify.Create {
+				w.logger.Debugf("Config file created")
 			} else {
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					w.logger.Debugf("Config file removed")
+				}
 				break
 			}
 
-			w.logger.Infof("Reloading config")
+			state, err := statFile(w.fs, chain.target)
+
+			if err != nil {
+				w.logger.Errorf("fsnotify: %s", err)
+				break
+			} else if !state.exists {
+				w.logger.Debugf("Config file does not exist")
+				break
+			}
+
+			// A single write may be notified several times
+			if w.isLoaded(state) {
+				w.logger.Debugf("Config file has already been loaded")
+				break
+			}
 
 			// Reload configuration
-			w.reload()
+			if err := w.reload(ctx, ReasonFSEvent); err != nil {
+				w.logger.Errorf("%v", err)
+			}
 		}
 	}
 }
 
+// watchDirs updates the list of watched directories from oldDirs to newDirs.
+func (w *watcher) watchDirs(oldDirs []string, newDirs []string) error {
+	watched := make(map[string]bool)
+
+	for _, dir := range newDirs {
+		watched[dir] = true
+	}
+
+	for _, dir := range oldDirs {
+		if !watched[dir] {
+			// The directory may not exist anymore
+			_ = w.fsWatcher.Remove(dir)
+		}
+	}
+
+	for _, dir := range newDirs {
+		w.logger.Debugf("Adding `%s` to the watch list", dir)
+
+		if err := w.fsWatcher.Add(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // readConfigCLIOptions loads config from cli arguments
-func (w *watcher) readConfigCLIOptions(conf Config) {
+func (w *watcher) readConfigCLIOptions(conf Config) error {
 	parser := flags.NewParser(conf, flags.Default)
 
 	if _, err := parser.Parse(); err != nil {
 		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
 			os.Exit(0)
-		} else {
-			w.logger.Fatalf("%v", err)
 		}
+
+		return fmt.Errorf("parsing command line options: %w", err)
 	}
+
+	return nil
 }
 
 // readConfigFile parses the config file defined by -f/--config
@@ -172,72 +281,85 @@ func (w *watcher) readConfigFile(conf Config) error {
 		return nil
 	}
 
+	w.fileRead = false
+	w.setLoaded(fileState{})
 	err := w.loadFile(conf, configFile)
 
-	if err != nil {
+	if os.IsNotExist(err) && w.optional {
+		w.logger.Debugf("Optional config file `%s` does not exist", configFile)
+		return nil
+	} else if err != nil {
 		return err
 	}
 
+	w.fileRead = true
+
 	return nil
 }
 
-// LoadFile parses the given YAML file into a Config.
-func (w *watcher) loadFile(conf Config, filename string) error {
-	content, err := ioutil.ReadFile(filename)
-
-	if err != nil {
-		return err
-	}
+// markFileLoaded signals that a configuration loaded from the config file has
+// been applied.
+func (w *watcher) markFileLoaded() {
+	w.fileLoadedOnce.Do(func() {
+		close(w.fileLoaded)
+	})
+}
 
-	ext := path.Ext(filename)
+// setLoaded remembers the state of the config file read by the current load.
+func (w *watcher) setLoaded(state fileState) {
+	w.loadedMu.Lock()
+	defer w.loadedMu.Unlock()
 
-	switch ext {
-	case ".yaml", ".yml":
-		err = w.parseYAML(conf, content)
-	case ".json":
-		err = w.parseJSON(conf, content)
-	case ".toml":
-		err = w.parseTOML(conf, content)
-	}
+	w.loaded = state
+}
 
-	if err != nil {
-		return fmt.Errorf("parsing file %s: %v", filename, err)
-	}
+// isLoaded tells if the config file in state is the one read by the last load.
+func (w *watcher) isLoaded(state fileState) bool {
+	w.loadedMu.Lock()
+	defer w.loadedMu.Unlock()
 
-	return nil
+	return state.equal(w.loaded)
 }
 
-// parseYAML parses the YAML input into a Config.
-func (w *watcher) parseYAML(conf Config, bytes []byte) error {
-	err := yaml.Unmarshal([]byte(bytes), conf)
+// LoadFile parses the given file into a Config according to its extension.
+func (w *watcher) loadFile(conf Config, filename string) error {
+	content, err := w.fs.ReadFile(filename)
 
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-// parseJSON parses the JSON input into a Config.
-func (w *watcher) parseJSON(conf Config, bytes []byte) error {
-	ast, err := hujson.Parse(bytes)
+	// An empty file is most likely being written
+	if len(bytes.TrimSpace(content)) == 0 {
+		return fmt.Errorf("%s: %w", filename, ErrFileNotReady)
+	}
 
-	if err != nil {
-		return err
+	// A change made once the file has been read does not have the same hash
+	if info, err := w.fs.Stat(filename); err == nil {
+		hash := sha256.Sum256(content)
+		w.setLoaded(fileState{
+			exists:  true,
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			hash:    hash[:],
+		})
 	}
 
-	ast.Standardize()
-	data := ast.Pack()
+	parser, ok := w.parsers[path.Ext(filename)]
 
-	return json.Unmarshal(data, conf)
-}
+	if !ok {
+		parser = w.defaultParser
+	}
 
-// parseTOML parses the TOML input into a Config.
-func (w *watcher) parseTOML(conf Config, bytes []byte) error {
-	err := toml.Unmarshal([]byte(bytes), conf)
+	if parser == nil {
+		w.logger.Warnf("Unknown format of config file `%s`, ignoring its content", filename)
+		return nil
+	}
+
+	err = parser(conf, content)
 
 	if err != nil {
-		return err
+		return fmt.Errorf("parsing file %s: %v", filename, err)
 	}
 
 	return nil